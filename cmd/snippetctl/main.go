@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/envelope"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models/mysql"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// masterKeysEnv holds the master keys when no -master-keys file is given.
+const masterKeysEnv = "SNIPPETBOX_MASTER_KEYS"
+
+const usage = `Usage: snippetctl [flags] <command> [arguments]
+
+Commands:
+  set-role <email> <role>  Give a user a role, one of: %s
+  rotate-keys [batch]      Re-wrap the data keys of snippets with the current
+                           master key, and encrypt unencrypted snippets, in
+                           batches of 100 or the given size
+
+Flags:
+`
+
+func main() {
+	var dsn string
+	flag.StringVar(&dsn, "dsn", "root:@(localhost:3306)/snippetbox?parseTime=true&charset=utf8mb4,utf8", "MySQL Data source name")
+	var masterKeys string
+	flag.StringVar(&masterKeys, "master-keys", "", "File of base64 master keys, current first (default $"+masterKeysEnv+")")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, strings.Join(models.Roles, ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	errorLog := log.New(os.Stderr, "", 0)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := openDB(dsn)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	defer db.Close()
+
+	users := &mysql.UserModel{DB: db}
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "set-role":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = setRole(users, args[0], args[1])
+	case "rotate-keys":
+		if len(args) > 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		batch := 100
+		if len(args) == 1 {
+			if batch, err = strconv.Atoi(args[0]); err != nil || batch < 1 {
+				errorLog.Fatalf("invalid batch size %q", args[0])
+			}
+		}
+		var keys *envelope.Keyring
+		keys, err = envelope.Load(masterKeys, masterKeysEnv)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		if keys == nil {
+			errorLog.Fatalf("no master keys, use -master-keys or $%s", masterKeysEnv)
+		}
+		err = rotateKeys(&mysql.SnippetModel{DB: db, Keys: keys}, batch)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+}
+
+func setRole(users *mysql.UserModel, email, role string) error {
+	user, err := users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("no user with email %q", email)
+		}
+		return err
+	}
+	if err := users.SetRole(user.ID, role); err != nil {
+		if errors.Is(err, models.ErrInvalidRole) {
+			return fmt.Errorf("invalid role %q, must be one of: %s", role, strings.Join(models.Roles, ", "))
+		}
+		return err
+	}
+	fmt.Printf("%s (#%d) is now %s\n", user.Email, user.ID, role)
+	return nil
+}
+
+// rotateKeys re-wraps data keys batch by batch, so that no transaction locks
+// many snippets for long, until every snippet uses the current master key.
+func rotateKeys(snippets *mysql.SnippetModel, batch int) error {
+	total := 0
+	for {
+		n, err := snippets.RotateKeys(batch)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+		fmt.Printf("%d snippets done\n", total)
+	}
+	fmt.Printf("every snippet now uses master key %s\n", snippets.Keys.CurrentID())
+	return nil
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const adminPerPage = 25
+
+func (app *application) adminHome(w http.ResponseWriter, r *http.Request) {
+	snippetCounts, err := app.snippets.Count()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userCounts, err := app.users.Count()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin.page.tmpl", &TemplateData{
+		SnippetCounts: snippetCounts,
+		UserCounts:    userCounts,
+	})
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	page := newPagination(r, adminPerPage)
+	users, err := app.users.Search(query, page.Limit(), page.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin-users.page.tmpl", &TemplateData{
+		Pagination: page,
+		Query:      query,
+		Roles:      models.Roles,
+		Users:      users[:page.SetResults(len(users))],
+	})
+}
+
+// adminUser loads the user named in the URL. It writes the error response
+// and returns nil if there is no such user.
+func (app *application) adminUser(w http.ResponseWriter, r *http.Request) *models.User {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return user
+}
+
+func (app *application) adminSetRole(w http.ResponseWriter, r *http.Request) {
+	if !app.can(r, models.PermManageRoles) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("role")
+	form.PermittedValues("role", models.Roles...)
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if user.ID == app.authenticatedUser(r).ID {
+		app.session.Put(r, "flash", "You can't change your own role")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.SetRole(user.ID, form.Get("role"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminSetRole, audit.Details{
+		"user_id": user.ID,
+		"from":    user.Role,
+		"to":      form.Get("role"),
+	})
+	app.session.Put(r, "flash", "The role of "+user.Name+" has been updated")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminSetActive returns a handler that deactivates or reactivates a user.
+// Deactivated users are logged out everywhere.
+func (app *application) adminSetActive(active bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.adminUser(w, r)
+		if user == nil {
+			return
+		}
+
+		if user.ID == app.authenticatedUser(r).ID {
+			app.session.Put(r, "flash", "You can't deactivate your own account")
+			http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+			return
+		}
+
+		err := app.users.SetActive(user.ID, active)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if active {
+			app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminReactivate, audit.Details{"user_id": user.ID})
+			app.session.Put(r, "flash", user.Name+" has been reactivated")
+		} else {
+			if err := app.logoutEverywhere(user.ID); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminDeactivate, audit.Details{"user_id": user.ID})
+			app.session.Put(r, "flash", user.Name+" has been deactivated")
+		}
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+	}
+}
+
+// adminResetPassword makes the user choose a new password the next time they
+// log in, and logs them out so that happens straight away.
+func (app *application) adminResetPassword(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.users.RequirePasswordReset(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if user.ID != app.authenticatedUser(r).ID {
+		if err := app.logoutEverywhere(user.ID); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminResetPass, audit.Details{"user_id": user.ID})
+	app.session.Put(r, "flash", user.Name+" must choose a new password at their next login")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) logoutEverywhere(userID int) error {
+	if err := app.session.RevokeAll(userID); err != nil {
+		return err
+	}
+	return app.rememberTokens.RevokeAll(userID)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	page := newPagination(r, adminPerPage)
+	snippets, err := app.snippets.Search(query, page.Limit(), page.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin-snippets.page.tmpl", &TemplateData{
+		Pagination: page,
+		Query:      query,
+		Snippets:   snippets[:page.SetResults(len(snippets))],
+	})
+}
+
+// adminSnippet loads the snippet named in the URL, even if it has expired.
+// It writes the error response and returns nil if there is no such snippet.
+func (app *application) adminSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	s, err := app.snippets.GetAny(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return s
+}
+
+// adminSetHidden returns a handler that hides a snippet from everyone but
+// moderators, or makes it visible again.
+func (app *application) adminSetHidden(hidden bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := app.adminSnippet(w, r)
+		if s == nil {
+			return
+		}
+
+		err := app.snippets.SetHidden(s.ID, hidden)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if hidden {
+			app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminHide, audit.Details{"snippet_id": s.ID})
+			app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(s.ID)+" has been hidden")
+		} else {
+			app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminUnhide, audit.Details{"snippet_id": s.ID})
+			app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(s.ID)+" is visible again")
+		}
+		http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+	}
+}
+
+func (app *application) adminDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.adminSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionAdminDelete, audit.Details{"snippet_id": s.ID, "title": s.Title})
+	app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(s.ID)+" has been deleted")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// auditFilter reads the audit log filter from the query string. Dates are
+// whole days in UTC, and until is inclusive.
+func auditFilter(r *http.Request) (audit.Filter, *forms.Form, error) {
+	form := forms.New(r.URL.Query())
+	f := audit.Filter{Action: form.Get("action")}
+	if v := form.Get("actor"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return f, form, err
+		}
+		f.ActorID = id
+	}
+	if v := form.Get("since"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return f, form, err
+		}
+		f.Since = t
+	}
+	if v := form.Get("until"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return f, form, err
+		}
+		f.Until = t.AddDate(0, 0, 1)
+	}
+	return f, form, nil
+}
+
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	filter, form, err := auditFilter(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	page := newPagination(r, adminPerPage)
+	filter.Limit, filter.Offset = page.Limit(), page.Offset()
+
+	events, err := app.auditLog.List(filter)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin-audit.page.tmpl", &TemplateData{
+		Actions:     audit.Actions,
+		AuditEvents: events[:page.SetResults(len(events))],
+		Form:        form,
+		Pagination:  page,
+	})
+}
+
+// adminAuditExport downloads every event matching the filter as JSON Lines.
+func (app *application) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	filter, _, err := auditFilter(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := app.auditLog.List(filter)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-`+time.Now().UTC().Format("20060102")+`.jsonl"`)
+	if err := audit.WriteJSONL(w, events); err != nil {
+		app.errorLog.Println(err)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+)
+
+func Test_adminUsers(t *testing.T) {
+	tests := []struct {
+		name               string
+		email              string
+		wantCode           int
+		wantHeaderLocation string
+		wantBody           []byte
+	}{
+		{"Unauthenticated", "", http.StatusSeeOther, "/user/login", nil},
+		{"User", "alice@example.com", http.StatusForbidden, "", nil},
+		{"Admin", "root@example.com", http.StatusOK, "", []byte(`<form action="/admin/users/1/role" method="POST">`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, header, body := ts.get(t, "/admin/users")
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantHeaderLocation {
+				t.Errorf("want %q; got %q", tt.wantHeaderLocation, loc)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_adminSetRole(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "root@example.com")
+	_, _, body := ts.get(t, "/admin/users")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		role      string
+		wantCode  int
+		wantFlash []byte
+	}{
+		{"Promote", "/admin/users/1/role", "moderator", http.StatusSeeOther, []byte("The role of Alice has been updated")},
+		{"Own role", "/admin/users/2/role", "user", http.StatusSeeOther, []byte("You can&#39;t change your own role")},
+		{"Invalid role", "/admin/users/1/role", "superuser", http.StatusBadRequest, nil},
+		{"Unknown user", "/admin/users/9/role", "user", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("role", tt.role)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/admin/users")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+}
+
+func Test_adminUserActions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "root@example.com")
+	_, _, body := ts.get(t, "/admin/users")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash []byte
+	}{
+		{"Deactivate", "/admin/users/1/deactivate", http.StatusSeeOther, []byte("Alice has been deactivated")},
+		{"Deactivate self", "/admin/users/2/deactivate", http.StatusSeeOther, []byte("You can&#39;t deactivate your own account")},
+		{"Reactivate", "/admin/users/1/activate", http.StatusSeeOther, []byte("Alice has been reactivated")},
+		{"Reset password", "/admin/users/1/reset-password", http.StatusSeeOther, []byte("Alice must choose a new password at their next login")},
+		{"Unknown user", "/admin/users/9/deactivate", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/admin/users")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+}
+
+func Test_adminSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/admin/snippets")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	ts.login(t, "alice@example.com")
+	code, _, _ = ts.get(t, "/admin/snippets")
+	if code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	ts.login(t, "root@example.com")
+	code, _, body := ts.get(t, "/admin/snippets")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if want := []byte(`<form action="/admin/snippets/1/hide" method="POST">`); !bytes.Contains(body, want) {
+		t.Errorf("want body to contain %q", want)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash []byte
+	}{
+		{"Hide", "/admin/snippets/1/hide", http.StatusSeeOther, []byte("Snippet #1 has been hidden")},
+		{"Unhide", "/admin/snippets/3/unhide", http.StatusSeeOther, []byte("Snippet #3 is visible again")},
+		{"Delete", "/admin/snippets/1/delete", http.StatusSeeOther, []byte("Snippet #1 has been deleted")},
+		{"Expired snippet", "/admin/snippets/5/delete", http.StatusSeeOther, []byte("Snippet #5 has been deleted")},
+		{"Unknown snippet", "/admin/snippets/9/delete", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/admin/snippets")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+}
+
+func Test_requireAuthenticationPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "reset@example.com")
+
+	code, header, _ := ts.get(t, "/user/profile")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := header.Get("Location"); loc != "/user/change-password" {
+		t.Errorf("want %q; got %q", "/user/change-password", loc)
+	}
+
+	code, _, _ = ts.get(t, "/user/change-password")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+}
+
+func Test_adminAudit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "root@example.com")
+	// A failed login leaves the admin's session alone.
+	ts.login(t, "nobody@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"All", "/admin/audit", http.StatusOK, []byte("<th>user.login_failed</th>")},
+		{"By actor", "/admin/audit?actor=2", http.StatusOK, []byte("<th>#2</th>")},
+		{"Bad date", "/admin/audit?since=yesterday", http.StatusBadRequest, nil},
+		{"Export", "/admin/audit/export?action=user.login_failed", http.StatusOK, []byte(`"email":"nobody@example.com"`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	events, err := app.auditLog.List(audit.Filter{ActorID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != audit.ActionLogin {
+		t.Errorf("want the admin's login to be recorded; got %+v", events)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+)
+
+// Routes whose forms are protected against spam.
+const (
+	signupRoute        = "/user/signup"
+	createSnippetRoute = "/snippet/create"
+)
+
+// antispamPages maps the pages with protected forms to the route the form
+// posts to, so that render can add the fields the checks need.
+var antispamPages = map[string]string{
+	"signup.page.tmpl": signupRoute,
+	"create.page.tmpl": createSnippetRoute,
+}
+
+// checkSpam verifies a protected form. If the submission looks automated, an
+// error is added to form so that it is shown again. Bots aren't told which
+// check they failed.
+func (app *application) checkSpam(r *http.Request, route string, form *forms.Form) {
+	if err := app.antispam.Verify(route, r.PostForm); err != nil {
+		app.infoLog.Printf("rejected %s from %s: %v", route, clientIP(r), err)
+		form.Errors.Add("antispam", "We couldn't check that you're not a bot. Make sure JavaScript is on, wait a moment and send the form again.")
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxCollectionSnippets = 50
+
+// collectionFromURL loads the collection named in the URL. It writes the
+// error response and returns nil if there is no such collection, or it is
+// private and the user doesn't own it.
+func (app *application) collectionFromURL(w http.ResponseWriter, r *http.Request) *models.Collection {
+	c, err := app.collections.GetBySlug(chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	if c.Visibility == models.VisibilityPrivate && !app.ownsCollection(r, c) {
+		app.notFound(w)
+		return nil
+	}
+	return c
+}
+
+// ownCollection loads the collection named in the URL if the logged in user
+// owns it. Otherwise it writes the error response and returns nil.
+func (app *application) ownCollection(w http.ResponseWriter, r *http.Request) *models.Collection {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return nil
+	}
+	if !app.ownsCollection(r, c) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+	return c
+}
+
+func (app *application) ownsCollection(r *http.Request, c *models.Collection) bool {
+	user := app.authenticatedUser(r)
+	return user != nil && user.ID == c.UserID
+}
+
+func (app *application) showCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return
+	}
+	app.render(w, r, "collection.page.tmpl", &TemplateData{
+		Collection: c,
+		Form:       forms.New(nil),
+	})
+}
+
+// collectionFiles returns the files of every snippet in a collection, in a
+// directory per snippet that sorts in the collection's order.
+func collectionFiles(c *models.Collection) []*models.File {
+	var files []*models.File
+	for i, s := range c.Snippets {
+		for _, f := range s.Files {
+			name := fmt.Sprintf("%02d-snippet-%d/%s", i+1, s.ID, f.Name)
+			files = append(files, &models.File{Name: name, Content: f.Content})
+		}
+	}
+	return files
+}
+
+// rawCollection serves the files of a collection as one plain text file.
+func (app *application) rawCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	for i, f := range collectionFiles(c) {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		fmt.Fprintf(w, "==> %s <==\n%s\n", f.Name, f.Content)
+	}
+}
+
+// downloadCollection serves the files of a collection as a ZIP archive.
+func (app *application) downloadCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, c.Slug))
+	if err := writeZip(w, collectionFiles(c)); err != nil {
+		app.errorLog.Println(err)
+	}
+}
+
+func (app *application) userCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := app.collections.ByUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "collections.page.tmpl", &TemplateData{
+		Collections: collections,
+	})
+}
+
+func (app *application) createCollectionForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "create-collection.page.tmpl", &TemplateData{
+		Form: forms.New(url.Values{"visibility": {models.VisibilityPrivate}}),
+	})
+}
+
+func (app *application) createCollection(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("title", "slug", "visibility")
+	form.MaxLength("title", 100)
+	form.MaxLength("slug", 50)
+	form.ValidSlug("slug")
+	form.MaxLength("description", 2000)
+	form.PermittedValues("visibility", models.Visibilities...)
+	if !form.Valid() {
+		app.render(w, r, "create-collection.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	_, err = app.collections.Insert(app.authenticatedUser(r).ID, form.Get("slug"), form.Get("title"), form.Get("description"), form.Get("visibility"))
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			form.Errors.Add("slug", "This address is already taken")
+			app.render(w, r, "create-collection.page.tmpl", &TemplateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection created! Add snippets to it from their pages.")
+	http.Redirect(w, r, "/c/"+form.Get("slug"), http.StatusSeeOther)
+}
+
+func (app *application) editCollectionForm(w http.ResponseWriter, r *http.Request) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	data := url.Values{
+		"title":       {c.Title},
+		"description": {c.Description},
+		"visibility":  {c.Visibility},
+	}
+	for i, s := range c.Snippets {
+		data.Set(fmt.Sprintf("position.%d", s.ID), strconv.Itoa(i+1))
+	}
+	unavailable, err := app.collections.Unavailable(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "edit-collection.page.tmpl", &TemplateData{
+		Collection:  c,
+		Form:        forms.New(data),
+		Unavailable: unavailable,
+	})
+}
+
+// editCollection updates a collection, and reorders and removes its snippets.
+// Snippets are put in the order of their position field, ties keeping their
+// current order. Expired and hidden snippets still take up room, so they can
+// be removed too.
+func (app *application) editCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("title", "visibility")
+	form.MaxLength("title", 100)
+	form.MaxLength("description", 2000)
+	form.PermittedValues("visibility", models.Visibilities...)
+
+	unavailable, err := app.collections.Unavailable(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var keep, remove []*models.Snippet
+	positions := map[int]int{}
+	for _, s := range c.Snippets {
+		if form.Get(fmt.Sprintf("remove.%d", s.ID)) != "" {
+			remove = append(remove, s)
+			continue
+		}
+		field := fmt.Sprintf("position.%d", s.ID)
+		form.IntBetween(field, 1, maxCollectionSnippets)
+		positions[s.ID], _ = strconv.Atoi(form.Get(field))
+		keep = append(keep, s)
+	}
+	for _, s := range unavailable {
+		if form.Get(fmt.Sprintf("remove.%d", s.ID)) != "" {
+			remove = append(remove, s)
+		}
+	}
+	if !form.Valid() {
+		app.render(w, r, "edit-collection.page.tmpl", &TemplateData{Collection: c, Form: form, Unavailable: unavailable})
+		return
+	}
+
+	sort.SliceStable(keep, func(i, j int) bool {
+		return positions[keep[i].ID] < positions[keep[j].ID]
+	})
+	order := []int{}
+	for _, s := range keep {
+		order = append(order, s.ID)
+	}
+
+	if err := app.collections.Update(c.ID, form.Get("title"), form.Get("description"), form.Get("visibility")); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for _, s := range remove {
+		if err := app.collections.RemoveSnippet(c.ID, s.ID); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	if err := app.collections.Reorder(c.ID, order); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection saved!")
+	http.Redirect(w, r, "/c/"+c.Slug, http.StatusSeeOther)
+}
+
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+	if err := app.collections.Delete(c.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", "Collection deleted")
+	http.Redirect(w, r, "/user/collections", http.StatusSeeOther)
+}
+
+// collectSnippet adds the snippet in the URL to one of the user's
+// collections.
+func (app *application) collectSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	c, err := app.collections.GetBySlug(r.PostForm.Get("collection"))
+	if errors.Is(err, models.ErrNoRecord) || (err == nil && !app.ownsCollection(r, c)) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// c.Snippets leaves out expired and hidden snippets, which still take
+	// up room.
+	n, err := app.collections.CountSnippets(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	flash := fmt.Sprintf("Added to %s", c.Title)
+	if n >= maxCollectionSnippets {
+		flash = fmt.Sprintf("A collection can have at most %d snippets", maxCollectionSnippets)
+	} else if err := app.collections.AddSnippet(c.ID, s.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", flash)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
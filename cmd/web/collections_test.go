@@ -0,0 +1,213 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func Test_showCollection(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Public", "", "/c/onboarding", http.StatusOK, []byte(`<a href="/snippet/4/raw/notes.md">notes.md</a>`)},
+		{"Description", "", "/c/onboarding", http.StatusOK, []byte("Read <em>these</em> first")},
+		{"Raw", "", "/c/onboarding/raw", http.StatusOK, []byte("==> 02-snippet-4/notes.md <==\n*Bashō*\n")},
+		{"Private", "", "/c/drafts", http.StatusNotFound, nil},
+		{"Private, other user", "root@example.com", "/c/drafts", http.StatusNotFound, nil},
+		{"Private, owner", "alice@example.com", "/c/drafts", http.StatusOK, []byte("This collection is private.")},
+		{"Unknown", "", "/c/nothing", http.StatusNotFound, nil},
+		{"Profile", "", "/u/alice", http.StatusOK, []byte(`<a href="/c/onboarding">Onboarding</a>`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_downloadCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/c/onboarding/zip")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{"01-snippet-1/haiku.txt", "02-snippet-4/haiku.txt", "02-snippet-4/notes.md"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("want %q; got %q", want, names)
+	}
+}
+
+func Test_createCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/collection/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		slug     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", "deploy-runbook", http.StatusSeeOther, nil},
+		{"Taken", "onboarding", http.StatusOK, []byte("This address is already taken")},
+		{"Invalid", "Deploy--Runbook", http.StatusOK, []byte("Use lowercase letters and digits, separated by single hyphens")},
+		{"Blank", "", http.StatusOK, []byte("This field cannot be blank")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Deploy runbook")
+			form.Add("slug", tt.slug)
+			form.Add("visibility", "unlisted")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/collection/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_editCollectionForm(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	code, _, body := ts.get(t, "/c/full/edit")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range [][]byte{[]byte("Gone"), []byte(`name="remove.5"`)} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func Test_editCollection(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		position string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Owner", "alice@example.com", "/c/onboarding/edit", "2", http.StatusSeeOther, nil},
+		{"Invalid position", "alice@example.com", "/c/onboarding/edit", "0", http.StatusOK, []byte("This field must be a whole number from 1 to 50")},
+		{"Other user", "root@example.com", "/c/onboarding/edit", "2", http.StatusForbidden, nil},
+		{"Delete", "alice@example.com", "/c/onboarding/delete", "", http.StatusSeeOther, nil},
+		{"Delete, other user", "root@example.com", "/c/onboarding/delete", "", http.StatusForbidden, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/user/collections")
+
+			form := url.Values{}
+			form.Add("title", "Onboarding")
+			form.Add("visibility", "public")
+			form.Add("position.1", tt.position)
+			form.Add("position.4", "1")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_collectSnippet(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		collection string
+		wantCode   int
+		wantFlash  []byte
+	}{
+		{"Own collection", "alice@example.com", "drafts", http.StatusSeeOther, []byte("Added to Drafts")},
+		{"Full collection", "alice@example.com", "full", http.StatusSeeOther, []byte("A collection can have at most 50 snippets")},
+		{"Other user's collection", "root@example.com", "drafts", http.StatusBadRequest, nil},
+		{"Unknown collection", "alice@example.com", "nothing", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/snippet/1")
+
+			form := url.Values{}
+			form.Add("collection", tt.collection)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/snippet/1/collect", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/snippet/1")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+}
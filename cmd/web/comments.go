@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const commentMaxLength = 5000
+
+// canModerateComments reports whether the logged in user may hide and delete
+// any comment on s: the owner of the snippet and moderators can.
+func (app *application) canModerateComments(r *http.Request, s *models.Snippet) bool {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false
+	}
+	return (s.UserID != 0 && s.UserID == user.ID) || user.Can(models.PermModerateSnippets)
+}
+
+func commentURL(c *models.Comment) string {
+	return fmt.Sprintf("/snippet/%d#comment-%d", c.SnippetID, c.ID)
+}
+
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("body")
+	form.MaxLength("body", commentMaxLength)
+	form.IntBetween("parent_id", 1, math.MaxInt32)
+
+	// Line comments are on the chosen file, which is the only one if there
+	// is just one.
+	var fileName string
+	var lineStart, lineEnd int
+	if form.Get("line_start") != "" || form.Get("line_end") != "" {
+		file := s.File(form.Get("file"))
+		if file == nil && len(s.Files) == 1 && form.Get("file") == "" {
+			file = s.Files[0]
+		}
+		if file == nil {
+			form.Errors.Add("file", "Choose one of the files")
+		} else {
+			fileName = file.Name
+			lines := len(splitLines(file.Content))
+			form.IntBetween("line_start", 1, lines)
+			form.IntBetween("line_end", 1, lines)
+		}
+		lineStart, _ = strconv.Atoi(form.Get("line_start"))
+		lineEnd, _ = strconv.Atoi(form.Get("line_end"))
+		if lineEnd == 0 {
+			lineEnd = lineStart
+		} else if lineStart == 0 {
+			form.Errors.Add("line_start", "This field cannot be blank")
+		} else if lineEnd < lineStart {
+			form.Errors.Add("line_end", "The last line can't be before the first")
+		}
+	}
+	if !form.Valid() {
+		app.renderSnippet(w, r, s, form)
+		return
+	}
+
+	var parentID int
+	if v := form.Get("parent_id"); v != "" {
+		id, _ := strconv.Atoi(v)
+		parent, err := app.comments.Get(id)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && parent.SnippetID != s.ID) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		parentID = parent.ID
+		if parent.ParentID != 0 {
+			parentID = parent.ParentID
+		}
+		fileName, lineStart, lineEnd = parent.File, parent.LineStart, parent.LineEnd
+	}
+
+	id, err := app.comments.Insert(s.ID, app.authenticatedUser(r).ID, parentID, fileName, lineStart, lineEnd, form.Get("body"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, commentURL(&models.Comment{ID: id, SnippetID: s.ID}), http.StatusSeeOther)
+}
+
+// commentFromURL loads the comment named in the URL and the snippet it is
+// on. It writes the error response and returns nil if either can't be seen.
+func (app *application) commentFromURL(w http.ResponseWriter, r *http.Request) (*models.Comment, *models.Snippet) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, nil
+	}
+	c, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil
+	}
+	s, err := app.snippets.Get(c.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil
+	}
+	if s.Hidden && !app.can(r, models.PermModerateSnippets) {
+		app.notFound(w)
+		return nil, nil
+	}
+	return c, s
+}
+
+func (app *application) editCommentForm(w http.ResponseWriter, r *http.Request) {
+	c, _ := app.commentFromURL(w, r)
+	if c == nil {
+		return
+	}
+	if c.UserID != app.authenticatedUser(r).ID || c.Deleted {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	app.render(w, r, "comment.page.tmpl", &TemplateData{
+		Comment: c,
+		Form:    forms.New(url.Values{"body": {c.Body}}),
+	})
+}
+
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	c, _ := app.commentFromURL(w, r)
+	if c == nil {
+		return
+	}
+	if c.UserID != app.authenticatedUser(r).ID || c.Deleted {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("body")
+	form.MaxLength("body", commentMaxLength)
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &TemplateData{Comment: c, Form: form})
+		return
+	}
+
+	if err := app.comments.Update(c.ID, form.Get("body")); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, commentURL(c), http.StatusSeeOther)
+}
+
+// deleteComment lets the author of a comment delete it, as well as anyone who
+// can moderate the comments on the snippet. When moderators delete the first
+// comment of a thread, the whole thread goes. When its author does, the
+// replies of others are kept and the comment is left as a tombstone.
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	c, s := app.commentFromURL(w, r)
+	if c == nil {
+		return
+	}
+	if c.UserID != app.authenticatedUser(r).ID && !app.canModerateComments(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	if c.ParentID == 0 && !app.canModerateComments(r, s) {
+		kept, err := app.comments.Tombstone(c.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if kept {
+			app.session.Put(r, "flash", "The comment has been deleted")
+			http.Redirect(w, r, commentURL(c), http.StatusSeeOther)
+			return
+		}
+	}
+	if err := app.comments.Delete(c.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", "The comment has been deleted")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// setCommentHidden returns a handler that hides a comment from everyone but
+// its author and the snippet's moderators, or shows it again.
+func (app *application) setCommentHidden(hidden bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, s := app.commentFromURL(w, r)
+		if c == nil {
+			return
+		}
+		if !app.canModerateComments(r, s) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		if err := app.comments.SetHidden(c.ID, hidden); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		http.Redirect(w, r, commentURL(c), http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func Test_createComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("What a <em>lovely</em> pond")) {
+		t.Errorf("want the comment rendered as Markdown")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		body      string
+		parentID  string
+		lineStart string
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Valid", "/snippet/1/comments", "Nice", "", "", http.StatusSeeOther, nil},
+		{"On a line", "/snippet/1/comments", "Nice", "", "1", http.StatusSeeOther, nil},
+		{"Reply", "/snippet/1/comments", "Thanks", "1", "", http.StatusSeeOther, nil},
+		{"Empty body", "/snippet/1/comments", "", "", "", http.StatusOK, []byte("This field cannot be blank")},
+		{"Line out of range", "/snippet/1/comments", "Nice", "", "2", http.StatusOK, []byte("This field must be a whole number from 1 to 1")},
+		{"Unknown parent", "/snippet/1/comments", "Nice", "9", "", http.StatusBadRequest, nil},
+		{"Hidden snippet", "/snippet/3/comments", "Nice", "", "", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("parent_id", tt.parentID)
+			form.Add("line_start", tt.lineStart)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_commentActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Author edits", "alice@example.com", "/comment/1/edit", http.StatusSeeOther},
+		{"Author deletes", "alice@example.com", "/comment/1/delete", http.StatusSeeOther},
+		{"Author hides", "alice@example.com", "/comment/1/hide", http.StatusForbidden},
+		{"Moderator edits", "root@example.com", "/comment/1/edit", http.StatusForbidden},
+		{"Moderator hides", "root@example.com", "/comment/1/hide", http.StatusSeeOther},
+		{"Moderator deletes", "root@example.com", "/comment/1/delete", http.StatusSeeOther},
+		{"Unknown comment", "alice@example.com", "/comment/9/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/snippet/1")
+
+			form := url.Values{}
+			form.Add("body", "Edited")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
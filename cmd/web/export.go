@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/signedurl"
+	"github.com/aesuhaendi/go-snippetbox/pkg/takeout"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// exportLifetime is how long a data export can be downloaded for.
+const exportLifetime = 7 * 24 * time.Hour
+
+var exportNameRX = regexp.MustCompile(`^[0-9a-f]{32}\.zip$`)
+
+// background runs fn in a goroutine, logging rather than crashing on panics.
+func (app *application) background(fn func()) {
+	app.jobs.Add(1)
+	go func() {
+		defer app.jobs.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Printf("background job: %v", err)
+			}
+		}()
+		fn()
+	}()
+}
+
+// requestExport starts building an archive of the user's data, unless one is
+// already being built for them.
+func (app *application) requestExport(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	name := hex.EncodeToString(b) + ".zip"
+	if err := app.exports.Start(user.ID, name); err != nil {
+		if errors.Is(err, models.ErrExportPending) {
+			app.session.Put(r, "flash", "We're still preparing your last export. You'll get a notification when it's ready to download.")
+			http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+			return
+		}
+		app.serverError(w, err)
+		return
+	}
+	app.background(func() {
+		if err := app.buildExport(user.ID, name); err != nil {
+			app.errorLog.Printf("data export for user %d: %v", user.ID, err)
+			app.exports.Delete(name)
+			app.notifications.Insert(user.ID, "Your data export failed. Please try again later.", "")
+		}
+	})
+	app.audit(r, user.ID, audit.ActionExport, nil)
+	app.session.Put(r, "flash", "We're preparing your data. You'll get a notification when it's ready to download.")
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// buildExport writes the user's archive to the export directory under the
+// name requestExport chose and notifies them with a signed link to it.
+func (app *application) buildExport(userID int, name string) error {
+	user, err := app.users.Get(userID)
+	if err != nil {
+		return err
+	}
+	snippets, err := app.snippets.ByUser(userID)
+	if err != nil {
+		return err
+	}
+	revisions := map[int][]*models.Revision{}
+	for _, s := range snippets {
+		if revisions[s.ID], err = app.snippets.Revisions(s.ID); err != nil {
+			return err
+		}
+	}
+	events, err := app.auditLog.List(audit.Filter{ActorID: userID})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(app.exportDir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = takeout.Write(f, &takeout.Archive{User: user, Snippets: snippets, Revisions: revisions, Events: events})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := app.exports.Finish(name); err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(exportLifetime)
+	link := app.signer.Sign("/export/"+name, expires)
+	return app.notifications.Insert(userID, "Your data export is ready. The link works until "+humanDate(expires)+".", link)
+}
+
+// downloadExport serves an archive made by buildExport. The signed link is
+// all that's needed, so it works from any device, but only while the archive
+// has a record: purging an account deletes the records of its archives.
+func (app *application) downloadExport(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !exportNameRX.MatchString(name) {
+		app.notFound(w)
+		return
+	}
+	switch err := app.signer.Verify(r.URL); err {
+	case nil:
+	case signedurl.ErrExpired:
+		app.clientError(w, http.StatusGone)
+		return
+	default:
+		app.notFound(w)
+		return
+	}
+	e, err := app.exports.Get(name)
+	if errors.Is(err, models.ErrNoRecord) || err == nil && !e.Ready {
+		app.clientError(w, http.StatusGone)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(app.exportDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			app.clientError(w, http.StatusGone)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="snippetbox-export.zip"`)
+	w.Header().Add("Cache-Control", "no-store")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// deleteOldExports removes archives whose links have expired, and those left
+// without a record, such as the archives of purged accounts.
+func (app *application) deleteOldExports() error {
+	if _, err := app.exports.DeleteExpired(exportLifetime); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(app.exportDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !exportNameRX.MatchString(e.Name()) {
+			continue
+		}
+		if _, err := app.exports.Get(e.Name()); errors.Is(err, models.ErrNoRecord) {
+			if err := os.Remove(filepath.Join(app.exportDir, e.Name())); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *application) userNotifications(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	notifications, err := app.notifications.Latest(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.notifications.MarkAllRead(user.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "notifications.page.tmpl", &TemplateData{
+		Notifications: notifications,
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/diff"
+	"github.com/aesuhaendi/go-snippetbox/pkg/formatter"
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/highlight"
+	"github.com/aesuhaendi/go-snippetbox/pkg/markdown"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+const (
+	maxSnippetFiles = 10
+	maxFileLength   = 512 * 1024
+)
+
+// CodeFile is a highlighted file of a snippet. Markdown files are also
+// rendered, unless their source was asked for.
+type CodeFile struct {
+	Name     string
+	Language string
+	Lines    []*CodeLine
+	Markdown template.HTML
+}
+
+// Threads returns the threads anchored to the lines of f.
+func (f *CodeFile) Threads() []*models.Comment {
+	threads := []*models.Comment{}
+	for _, l := range f.Lines {
+		threads = append(threads, l.Threads...)
+	}
+	return threads
+}
+
+// CodeLine is a line of a file, with the comment threads anchored to it.
+// Threads are shown after the last line of their range.
+type CodeLine struct {
+	Number  int
+	HTML    template.HTML
+	Threads []*models.Comment
+}
+
+// FileDiff is what a fork changed in one file.
+type FileDiff struct {
+	Name  string
+	Lines []diff.Line
+}
+
+func splitLines(content string) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), "\n")
+}
+
+// codeFiles highlights files, renders the Markdown ones unless source is
+// set, and puts each anchored thread on the last line of its range. The
+// threads that aren't anchored, or whose file or lines no longer exist, are
+// returned separately.
+func codeFiles(files []*models.File, threads []*models.Comment, source bool) ([]*CodeFile, []*models.Comment) {
+	var code []*CodeFile
+	byName := map[string]*CodeFile{}
+	for _, f := range files {
+		cf := &CodeFile{Name: f.Name, Language: highlight.Language(f.Name)}
+		for i, html := range highlight.Lines(cf.Language, f.Content) {
+			cf.Lines = append(cf.Lines, &CodeLine{Number: i + 1, HTML: html})
+		}
+		if cf.Language == "markdown" && !source {
+			cf.Markdown = markdown.RenderDocument(f.Content)
+		}
+		code = append(code, cf)
+		byName[f.Name] = cf
+	}
+
+	general := []*models.Comment{}
+	for _, t := range threads {
+		cf := byName[t.File]
+		if cf == nil || t.LineEnd < 1 || t.LineEnd > len(cf.Lines) {
+			general = append(general, t)
+			continue
+		}
+		line := cf.Lines[t.LineEnd-1]
+		line.Threads = append(line.Threads, t)
+	}
+	return code, general
+}
+
+var anchorRX = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// fileAnchor returns the HTML id of a file.
+func fileAnchor(file string) string {
+	return "file-" + anchorRX.ReplaceAllString(file, "-")
+}
+
+// lineAnchor returns the HTML id of a line of a file.
+func lineAnchor(file string, line int) string {
+	return fileAnchor(file) + "-L" + strconv.Itoa(line)
+}
+
+// filesForm returns a form for the files of a snippet.
+func filesForm(data url.Values, files []*models.File) url.Values {
+	for i, f := range files {
+		data.Set(fmt.Sprintf("file_name.%d", i), f.Name)
+		data.Set(fmt.Sprintf("file_content.%d", i), f.Content)
+	}
+	return data
+}
+
+// snippetFiles checks the files of the create and edit forms and returns
+// them. Files without a name are named after their position. If format is
+// checked, files in a language with a formatter are formatted, and those
+// that can't be are reported under their content.
+func snippetFiles(form *forms.Form) []*models.File {
+	indexes := form.Indexes("file_name")
+	if len(indexes) == 0 {
+		form.Errors.Add("files", "Add at least one file")
+	} else if len(indexes) > maxSnippetFiles {
+		form.Errors.Add("files", fmt.Sprintf("A snippet can have at most %d files", maxSnippetFiles))
+	}
+
+	files := []*models.File{}
+	seen := map[string]bool{}
+	for n, i := range indexes {
+		nameField, contentField := fmt.Sprintf("file_name.%d", i), fmt.Sprintf("file_content.%d", i)
+		form.Required(contentField)
+		form.MaxLength(contentField, maxFileLength)
+		form.Set(nameField, strings.TrimSpace(form.Get(nameField)))
+		form.MaxLength(nameField, 255)
+		form.ValidFileName(nameField)
+
+		name := form.Get(nameField)
+		if name == "" {
+			name = fmt.Sprintf("file%d.txt", n+1)
+		}
+		if seen[name] {
+			form.Errors.Add(nameField, "Another file has this name")
+		}
+		seen[name] = true
+
+		content := form.Get(contentField)
+		if f := formatter.For(highlight.Language(name)); f != nil && form.Get("format") != "" && content != "" && form.Errors.Get(contentField) == "" {
+			formatted, err := f.Format(content)
+			if err != nil {
+				form.Errors.Add(contentField, fmt.Sprintf("This file couldn't be formatted: %s", err))
+			} else {
+				content = formatted
+			}
+		}
+		files = append(files, &models.File{Name: name, Content: content})
+	}
+	return files
+}
+
+// fileDiffs compares the files of a fork with those of its parent, by name.
+// Files that were added or removed show as inserted or deleted in full.
+func fileDiffs(parent, fork *models.Snippet) []*FileDiff {
+	var diffs []*FileDiff
+	for _, f := range fork.Files {
+		var old []string
+		if p := parent.File(f.Name); p != nil {
+			old = splitLines(p.Content)
+		}
+		diffs = append(diffs, &FileDiff{f.Name, diff.Lines(old, splitLines(f.Content))})
+	}
+	for _, p := range parent.Files {
+		if fork.File(p.Name) == nil {
+			diffs = append(diffs, &FileDiff{p.Name, diff.Lines(splitLines(p.Content), nil)})
+		}
+	}
+	return diffs
+}
+
+// previewSnippet renders the files of the create and edit forms as they
+// would be shown, for the preview next to the form.
+func (app *application) previewSnippet(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	files := snippetFiles(form)
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	code, _ := codeFiles(files, nil, false)
+	app.renderFragment(w, "preview.page.tmpl", &TemplateData{Files: code})
+}
+
+// rawFile serves a file of a snippet as plain text.
+func (app *application) rawFile(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	name, err := pathParam(r, "name")
+	f := s.File(name)
+	if err != nil || f == nil {
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, f.Content)
+}
+
+// downloadSnippet serves every file of a snippet as a ZIP archive.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippet-%d.zip"`, s.ID))
+	if err := writeZip(w, s.Files); err != nil {
+		app.errorLog.Println(err)
+	}
+}
+
+func writeZip(w io.Writer, files []*models.File) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.Content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
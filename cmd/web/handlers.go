@@ -3,12 +3,17 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
 	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/oidc"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -57,10 +62,133 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	app.session.Put(r, "flash", "Your password has been updated!")
+	if err := app.session.RevokeOthers(r, id); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.rememberTokens.RevokeAll(id); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	clearRememberMeCookie(w)
+	app.session.RenewToken(r)
+	app.audit(r, id, audit.ActionPasswordChange, nil)
+	app.session.Put(r, "flash", "Your password has been updated! You have been logged out of your other sessions.")
 	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
 }
 
+func (app *application) closeAccountForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "close-account.page.tmpl", &TemplateData{
+		Form:            forms.New(nil),
+		Reauthenticated: app.reauthenticated(r),
+	})
+}
+
+// closeAccountSSO sends the user to the identity provider to confirm who they
+// are, for users who log in that way and have no password to give.
+func (app *application) closeAccountSSO(w http.ResponseWriter, r *http.Request) {
+	app.startOIDC(w, r, "close-account")
+}
+
+// closeAccount deactivates the user's account, or schedules it for deletion
+// after a grace period. Either way logging in again undoes it. The user must
+// give their password, or have just confirmed who they are through single
+// sign-on. Wrong passwords count towards the login throttle.
+func (app *application) closeAccount(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	reauthenticated := app.reauthenticated(r)
+	form := forms.New(r.PostForm)
+	form.Required("action")
+	if !reauthenticated {
+		form.Required("password")
+	}
+	form.PermittedValues("action", "deactivate", "delete")
+	if form.Get("action") == "delete" {
+		form.Required("snippets")
+		form.PermittedValues("snippets", "delete", "anonymise")
+	}
+	if !form.Valid() {
+		app.render(w, r, "close-account.page.tmpl", &TemplateData{Form: form, Reauthenticated: reauthenticated})
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	if !reauthenticated {
+		ip, email := clientIP(r), strings.ToLower(user.Email)
+		status, err := app.checkLoginThrottle(ip, email)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !status.Allowed() {
+			form.Errors.Add("password", throttleMessage(status))
+			app.render(w, r, "close-account.page.tmpl", &TemplateData{Form: form})
+			return
+		}
+
+		id, err := app.authenticator.Authenticate(user.Email, form.Get("password"))
+		if err != nil || id != user.ID {
+			status, ferr := app.failLogin(ip, email)
+			if ferr != nil {
+				app.serverError(w, ferr)
+				return
+			}
+			if err != nil && !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			app.infoLog.Printf("password confirmation failed for %q from %s", email, ip)
+			if status.Locked {
+				form.Errors.Add("password", throttleMessage(status))
+			} else {
+				form.Errors.Add("password", "Password is incorrect")
+			}
+			app.render(w, r, "close-account.page.tmpl", &TemplateData{Form: form})
+			return
+		}
+		if err := app.accountThrottle.Succeed("account:" + email); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	var flash string
+	if form.Get("action") == "delete" {
+		deleteAfter := time.Now().Add(accountDeletionGracePeriod)
+		deleteSnippets := form.Get("snippets") == "delete"
+		if err := app.users.ScheduleDeletion(user.ID, deleteAfter, deleteSnippets); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, user.ID, audit.ActionDeleteRequest, audit.Details{
+			"delete_after":    deleteAfter.UTC(),
+			"delete_snippets": deleteSnippets,
+		})
+		flash = "Your account will be deleted on " + humanDate(deleteAfter) + ". Log in before then if you change your mind."
+	} else {
+		if err := app.users.Deactivate(user.ID); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, user.ID, audit.ActionDeactivate, nil)
+		flash = "Your account has been deactivated. Log in again to reactivate it."
+	}
+
+	if err := app.logoutEverywhere(user.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	clearRememberMeCookie(w)
+	app.session.Destroy(r)
+	app.session.Put(r, "flash", flash)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func (app *application) about(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "about.page.tmpl", &TemplateData{
 		Form: forms.New(nil),
@@ -79,23 +207,124 @@ func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// publicProfile shows a user's profile to anyone, unless they have made it
+// private, in which case only they can see it.
+func (app *application) publicProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.GetByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	self := app.authenticatedUser(r) != nil && app.authenticatedUser(r).ID == user.ID
+	if !user.Active || (!user.ProfilePublic && !self) {
+		app.notFound(w)
+		return
+	}
+
+	page := newPagination(r, 20)
+	snippets, err := app.snippets.PublicByUser(user.ID, page.Limit(), page.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	all, err := app.collections.ByUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	collections := []*models.Collection{}
+	for _, c := range all {
+		if c.Visibility == models.VisibilityPublic {
+			collections = append(collections, c)
+		}
+	}
+
+	app.render(w, r, "user.page.tmpl", &TemplateData{
+		Collections: collections,
+		Pagination:  page,
+		Snippets:    snippets[:page.SetResults(len(snippets))],
+		User:        user,
+	})
+}
+
+func (app *application) settingsForm(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	data := url.Values{}
+	data.Set("username", user.Username)
+	if user.ProfilePublic {
+		data.Set("profilePublic", "true")
+	}
+	app.render(w, r, "settings.page.tmpl", &TemplateData{
+		Form: forms.New(data),
+	})
+}
+
+func (app *application) settings(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("username")
+	form.ValidUsername("username")
+	if !form.Valid() {
+		app.render(w, r, "settings.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	err = app.users.UpdateProfile(app.authenticatedUser(r).ID, form.Get("username"), form.Get("profilePublic") != "")
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateUsername) {
+			form.Errors.Add("username", "Username is already taken")
+			app.render(w, r, "settings.page.tmpl", &TemplateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Your settings have been saved")
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	s, err := app.snippets.Latest()
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	popular, err := app.stars.MostStarred(time.Now().AddDate(0, 0, -7), 5)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tags, err := app.tags.Popular(cloudTags)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	app.render(w, r, "home.page.tmpl", &TemplateData{
-		Snippets: s,
+		MostStarred: popular,
+		Snippets:    s,
+		TagCloud:    tagCloud(tags),
 	})
 }
 
-func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
+// snippetFromURL loads the snippet named in the URL. It writes the error
+// response and returns nil if there is no such snippet, or it has been hidden
+// and the user isn't a moderator.
+func (app *application) snippetFromURL(w http.ResponseWriter, r *http.Request) *models.Snippet {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id < 1 {
 		app.notFound(w)
-		return
+		return nil
 	}
 
 	s, err := app.snippets.Get(id)
@@ -105,17 +334,88 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		} else {
 			app.serverError(w, err)
 		}
+		return nil
+	}
+	if s.Hidden && !app.can(r, models.PermModerateSnippets) {
+		app.notFound(w)
+		return nil
+	}
+	return s
+}
+
+func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+	app.renderSnippet(w, r, s, forms.New(nil))
+}
+
+// renderSnippet shows s with its comments. form is the new comment form.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) {
+	var starred bool
+	var collections []*models.Collection
+	if user := app.authenticatedUser(r); user != nil {
+		var err error
+		starred, err = app.stars.Starred(user.ID, s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		collections, err = app.collections.ByUser(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	threads, err := app.comments.BySnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
+	files, comments := codeFiles(s.Files, threads, r.URL.Query().Get("source") != "")
 
 	app.render(w, r, "show.page.tmpl", &TemplateData{
-		Snippet: s,
+		CanModerate: app.canModerateComments(r, s),
+		Collections: collections,
+		Comments:    comments,
+		Files:       files,
+		Form:        form,
+		Reasons:     models.ReportReasons,
+		Snippet:     s,
+		Starred:     starred,
+	})
+}
+
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	_, err := app.stars.Toggle(app.authenticatedUser(r).ID, s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+func (app *application) userStars(w http.ResponseWriter, r *http.Request) {
+	s, err := app.stars.ByUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "stars.page.tmpl", &TemplateData{
+		Snippets: s,
 	})
 }
 
 func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "create.page.tmpl", &TemplateData{
-		Form: forms.New(nil),
+		Form: forms.New(url.Values{"file_name.0": {""}}),
 	})
 }
 
@@ -127,28 +427,177 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
+	form.Required("title", "expires")
 	form.MaxLength("title", 100)
 	form.PermittedValues("expires", "365", "7", "1")
+	form.IntBetween("forked_from", 1, math.MaxInt32)
+	form.ValidTags("tags", maxTags, maxTagLength)
+	files := snippetFiles(form)
+	found := checkSecrets(form, files)
+	app.checkSpam(r, createSnippetRoute, form)
+
+	forkedFromID, _ := strconv.Atoi(form.Get("forked_from"))
+	if forkedFromID != 0 {
+		parent, err := app.snippets.Get(forkedFromID)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && parent.Hidden && !app.can(r, models.PermModerateSnippets)) {
+			form.Errors.Add("forked_from", "The snippet you forked no longer exists")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	if !form.Valid() {
 		app.render(w, r, "create.page.tmpl", &TemplateData{
-			Form: form,
+			Form:    form,
+			Secrets: found,
 		})
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	id, err := app.snippets.Insert(app.authenticatedUser(r).ID, forkedFromID, form.Get("title"), files, form.Tags("tags"), form.Get("expires"))
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	details := audit.Details{"snippet_id": id}
+	if forkedFromID != 0 {
+		details["forked_from"] = forkedFromID
+	}
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionSnippetCreate, details)
+	if len(found) > 0 {
+		app.audit(r, app.authenticatedUser(r).ID, audit.ActionSnippetSecrets, audit.Details{
+			"snippet_id": id,
+			"secrets":    secretsSummary(found),
+			"redacted":   form.Get("secrets") == "redact",
+		})
+	}
 	app.session.Put(r, "flash", "Snippet successfully created!")
 
 	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
 }
 
+// editSnippetForm shows the edit form to the owner of the snippet.
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.ownSnippet(w, r)
+	if s == nil {
+		return
+	}
+	app.render(w, r, "edit.page.tmpl", &TemplateData{
+		Form: forms.New(filesForm(url.Values{
+			"title": {s.Title},
+			"tags":  {tagsValue(s.Tags)},
+		}, s.Files)),
+		Snippet: s,
+	})
+}
+
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.ownSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("title")
+	form.MaxLength("title", 100)
+	form.ValidTags("tags", maxTags, maxTagLength)
+	files := snippetFiles(form)
+	found := checkSecrets(form, files)
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &TemplateData{
+			Form:    form,
+			Secrets: found,
+			Snippet: s,
+		})
+		return
+	}
+
+	if err := app.snippets.Update(s.ID, form.Get("title"), files, form.Tags("tags")); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionSnippetUpdate, audit.Details{"snippet_id": s.ID})
+	if len(found) > 0 {
+		app.audit(r, app.authenticatedUser(r).ID, audit.ActionSnippetSecrets, audit.Details{
+			"snippet_id": s.ID,
+			"secrets":    secretsSummary(found),
+			"redacted":   form.Get("secrets") == "redact",
+		})
+	}
+	app.session.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// ownSnippet loads the snippet named in the URL if the logged in user owns
+// it. Otherwise it writes the error response and returns nil.
+func (app *application) ownSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return nil
+	}
+	if s.UserID == 0 || s.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+	return s
+}
+
+// forkSnippetForm fills in the create form from a snippet, so that it can be
+// changed and published as a fork.
+func (app *application) forkSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+	app.render(w, r, "create.page.tmpl", &TemplateData{
+		Form: forms.New(filesForm(url.Values{
+			"title":       {s.Title},
+			"tags":        {tagsValue(s.Tags)},
+			"forked_from": {strconv.Itoa(s.ID)},
+		}, s.Files)),
+	})
+}
+
+// snippetDiff shows what a fork changed in the snippet it was forked from.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+	if s.ForkedFromID == 0 {
+		app.notFound(w)
+		return
+	}
+
+	parent, err := app.snippets.Get(s.ForkedFromID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if parent.Hidden && !app.can(r, models.PermModerateSnippets) {
+		app.notFound(w)
+		return
+	}
+
+	app.render(w, r, "diff.page.tmpl", &TemplateData{
+		FileDiffs: fileDiffs(parent, s),
+		Parent:    parent,
+		Snippet:   s,
+	})
+}
+
 func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "signup.page.tmpl", &TemplateData{
 		Form: forms.New(nil),
@@ -163,28 +612,34 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("name", "email", "password")
+	form.Required("name", "username", "email", "password")
 	form.MaxLength("name", 255)
+	form.ValidUsername("username")
 	form.MaxLength("email", 255)
 	form.MatchesPattern("email", forms.EmailRX)
 	form.MinLength("password", 10)
+	app.checkSpam(r, signupRoute, form)
 
 	if !form.Valid() {
 		app.render(w, r, "signup.page.tmpl", &TemplateData{Form: form})
 		return
 	}
 
-	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	err = app.users.Insert(form.Get("name"), form.Get("username"), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Address is already in use")
 			app.render(w, r, "signup.page.tmpl", &TemplateData{Form: form})
+		} else if errors.Is(err, models.ErrDuplicateUsername) {
+			form.Errors.Add("username", "Username is already taken")
+			app.render(w, r, "signup.page.tmpl", &TemplateData{Form: form})
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
+	app.audit(r, 0, audit.ActionSignup, audit.Details{"email": form.Get("email"), "username": form.Get("username")})
 	app.session.Put(r, "flash", "Your signup was successful. Please log in.")
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -204,18 +659,176 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
+
+	ip := clientIP(r)
+	email := strings.ToLower(strings.TrimSpace(form.Get("email")))
+
+	status, err := app.checkLoginThrottle(ip, email)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		app.serverError(w, err)
+		return
+	}
+	if !status.Allowed() {
+		app.infoLog.Printf("login throttled for %q from %s", email, ip)
+		app.audit(r, 0, audit.ActionLoginFailed, audit.Details{"email": email, "reason": "throttled"})
+		form.Errors.Add("generic", throttleMessage(status))
+		app.render(w, r, "login.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	id, err := app.authenticator.Authenticate(form.Get("email"), form.Get("password"))
+	if err != nil {
+		// The attempt counts even if a backend such as LDAP couldn't be
+		// reached, so that an outage doesn't turn throttling off for the
+		// others.
+		status, ferr := app.failLogin(ip, email)
+		if ferr != nil {
+			app.serverError(w, ferr)
+			return
+		}
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			app.serverError(w, err)
+			return
+		}
+		app.infoLog.Printf("login failed for %q from %s", email, ip)
+		app.audit(r, 0, audit.ActionLoginFailed, audit.Details{"email": email, "reason": "invalid credentials"})
+		if status.Locked {
+			app.infoLog.Printf("login locked for %q from %s after %d failures", email, ip, status.Failures)
+			form.Errors.Add("generic", throttleMessage(status))
+		} else {
 			form.Errors.Add("generic", "Email or Password is incorrect")
-			app.render(w, r, "login.page.tmpl", &TemplateData{Form: form})
+		}
+		app.render(w, r, "login.page.tmpl", &TemplateData{Form: form})
+		return
+	}
+
+	if err := app.accountThrottle.Succeed("account:" + email); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if form.Get("rememberMe") != "" {
+		token, err := app.rememberTokens.Issue(id, time.Now().Add(rememberMeLifetime))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		setRememberMeCookie(w, token)
+	}
+
+	app.session.RenewToken(r)
+	app.session.Put(r, "authenticatedUserID", id)
+	app.audit(r, id, audit.ActionLogin, audit.Details{"method": "password"})
+
+	urlPath := app.session.PopString(r, "redirectPathAfterLogin")
+	if urlPath == "" {
+		urlPath = "/snippet/create"
+	}
+
+	http.Redirect(w, r, urlPath, http.StatusSeeOther)
+}
+
+func (app *application) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	app.startOIDC(w, r, "")
+}
+
+// startOIDC sends the user to the identity provider. purpose is remembered
+// until the callback, which logs the user in if it is empty.
+func (app *application) startOIDC(w http.ResponseWriter, r *http.Request, purpose string) {
+	if app.oidc == nil {
+		app.notFound(w)
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	authURL, err := app.oidc.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "oidcState", state)
+	app.session.Put(r, "oidcNonce", nonce)
+	app.session.Put(r, "oidcVerifier", verifier)
+	app.session.Put(r, "oidcPurpose", purpose)
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+func (app *application) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.notFound(w)
+		return
+	}
+
+	state := app.session.PopString(r, "oidcState")
+	nonce := app.session.PopString(r, "oidcNonce")
+	verifier := app.session.PopString(r, "oidcVerifier")
+	purpose := app.session.PopString(r, "oidcPurpose")
+
+	q := r.URL.Query()
+	if state == "" || q.Get("state") != state {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		app.infoLog.Printf("single sign-on failed from %s: %s %s", clientIP(r), e, q.Get("error_description"))
+		app.session.Put(r, "flash", "Single sign-on failed, please try again")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	claims, err := app.oidc.Exchange(r.Context(), q.Get("code"), verifier, nonce)
+	if err != nil {
+		// A bad or replayed code, or an ID token that doesn't verify, is
+		// the client's problem as often as ours, so it isn't a 500.
+		app.errorLog.Printf("single sign-on exchange failed from %s: %v", clientIP(r), err)
+		app.session.Put(r, "flash", "Single sign-on failed, please try again")
+		if purpose == "close-account" {
+			http.Redirect(w, r, "/user/close-account", http.StatusSeeOther)
+		} else {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		}
+		return
+	}
+	if purpose == "close-account" {
+		app.reauthenticateOIDC(w, r, claims)
+		return
+	}
+
+	id, err := app.identities.Authenticate(claims.Issuer, claims.Subject, claims.Name, claims.Email, claims.EmailVerified)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			app.session.Put(r, "flash", "An account with this email address already exists. Please log in with your password.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else if errors.Is(err, models.ErrInvalidCredentials) {
+			app.infoLog.Printf("single sign-on refused for %q (%s) from %s", claims.Email, claims.Subject, clientIP(r))
+			app.audit(r, 0, audit.ActionLoginFailed, audit.Details{"email": claims.Email, "subject": claims.Subject, "reason": "account disabled"})
+			app.session.Put(r, "flash", "Your account cannot be used to log in")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
+	app.session.RenewToken(r)
 	app.session.Put(r, "authenticatedUserID", id)
+	app.audit(r, id, audit.ActionLogin, audit.Details{"method": "oidc", "subject": claims.Subject})
 
 	urlPath := app.session.PopString(r, "redirectPathAfterLogin")
 	if urlPath == "" {
@@ -225,12 +838,117 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, urlPath, http.StatusSeeOther)
 }
 
+// reauthenticateOIDC finishes confirming who the logged in user is before they
+// close their account. The provider must vouch for the identity linked to
+// this user, not just any account it knows. A mismatch counts as a failed
+// login.
+func (app *application) reauthenticateOIDC(w http.ResponseWriter, r *http.Request, claims *oidc.Claims) {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	id, err := app.identities.UserID(claims.Issuer, claims.Subject)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if err != nil || id != user.ID {
+		ip, email := clientIP(r), strings.ToLower(user.Email)
+		status, err := app.failLogin(ip, email)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.infoLog.Printf("single sign-on confirmation failed for %q (%s) from %s", email, claims.Subject, ip)
+		if status.Locked {
+			app.session.Put(r, "flash", throttleMessage(status))
+		} else {
+			app.session.Put(r, "flash", "Single sign-on did not confirm your account")
+		}
+		http.Redirect(w, r, "/user/close-account", http.StatusSeeOther)
+		return
+	}
+
+	app.session.Put(r, "reauthenticatedAt", int(time.Now().Unix()))
+	app.session.Put(r, "flash", "Your identity has been confirmed")
+	http.Redirect(w, r, "/user/close-account", http.StatusSeeOther)
+}
+
+// reauthenticated reports whether the user confirmed who they are through
+// single sign-on in the last reauthLifetime.
+func (app *application) reauthenticated(r *http.Request) bool {
+	at := app.session.GetInt(r, "reauthenticatedAt")
+	return at != 0 && time.Since(time.Unix(int64(at), 0)) < reauthLifetime
+}
+
 func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
-	app.session.Remove(r, "authenticatedUserID")
+	if err := app.revokeRememberMe(w, r); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.audit(r, app.authenticatedUser(r).ID, audit.ActionLogout, nil)
+	app.session.Destroy(r)
 	app.session.Put(r, "flash", "You've been logged out")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+func (app *application) userSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.session.List(app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "sessions.page.tmpl", &TemplateData{
+		Sessions:  sessions,
+		SessionID: app.session.ID(r),
+	})
+}
+
+func (app *application) revokeSession(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := r.PostForm.Get("id")
+	if id == app.session.ID(r) {
+		app.logoutUser(w, r)
+		return
+	}
+
+	ok, err := app.session.Revoke(app.session.GetInt(r, "authenticatedUserID"), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	app.session.Put(r, "flash", "The session has been logged out")
+	http.Redirect(w, r, "/user/sessions", http.StatusSeeOther)
+}
+
+func (app *application) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	id := app.session.GetInt(r, "authenticatedUserID")
+	if err := app.session.RevokeOthers(r, id); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.rememberTokens.RevokeAll(id); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	clearRememberMeCookie(w)
+	app.session.Destroy(r)
+	app.session.Put(r, "flash", "You've been logged out everywhere")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	rctx := chi.RouteContext(r.Context())
 	routePath := rctx.RoutePath
@@ -2,11 +2,26 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/antispam"
+	"github.com/aesuhaendi/go-snippetbox/pkg/auth"
+	"github.com/aesuhaendi/go-snippetbox/pkg/mocks"
+	"github.com/aesuhaendi/go-snippetbox/pkg/oidc"
+	"github.com/aesuhaendi/go-snippetbox/pkg/oidc/oidctest"
 )
 
 func Test_ping(t *testing.T) {
@@ -63,7 +78,10 @@ func Test_showSnippet(t *testing.T) {
 		wantBody []byte
 	}{
 		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
+		{"Markdown", "/snippet/4", http.StatusOK, []byte(`<div class="markdown-body"><p><em>Bashō</em></p>`)},
+		{"Markdown source", "/snippet/4?source=1", http.StatusOK, []byte(`<code>*Bashō*</code>`)},
 		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
+		{"Hidden ID", "/snippet/3", http.StatusNotFound, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
 		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
@@ -95,28 +113,33 @@ func Test_signupUser(t *testing.T) {
 	tests := []struct {
 		name         string
 		userName     string
+		userUsername string
 		userEmail    string
 		userPassword string
 		csrfToken    string
 		wantCode     int
 		wantBody     []byte
 	}{
-		{"Valid submission", "admin", "admin@localhost", "passwordadmin", csrfToken, http.StatusSeeOther, nil},
-		{"Empty name", "", "admin@localhost", "passwordadmin", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
-		{"Empty email", "admin", "", "passwordadmin", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
-		{"Empty password", "admin", "admin@localhost", "", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
-		{"Invalid email (incomplete domain)", "admin", "admin@example.", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
-		{"Invalid email (missing @)", "admin", "adminexample.com", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
-		{"Invalid email (missing local part)", "admin", "@example.com", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
-		{"Short password", "admin", "admin@localhost", "pass", csrfToken, http.StatusOK, []byte("This field is too short (minimum is 10 characters)")},
-		{"Duplicate email", "dupe", "dupe@example.com", "passwordadmin", csrfToken, http.StatusOK, []byte("Address is already in use")},
-		{"Invalid CSRF Token", "", "", "", "wrongToken", http.StatusBadRequest, nil},
+		{"Valid submission", "admin", "admin", "admin@localhost", "passwordadmin", csrfToken, http.StatusSeeOther, nil},
+		{"Empty name", "", "admin", "admin@localhost", "passwordadmin", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
+		{"Empty email", "admin", "admin", "", "passwordadmin", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
+		{"Empty password", "admin", "admin", "admin@localhost", "", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid email (incomplete domain)", "admin", "admin", "admin@example.", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
+		{"Invalid email (missing @)", "admin", "admin", "adminexample.com", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
+		{"Invalid email (missing local part)", "admin", "admin", "@example.com", "passwordadmin", csrfToken, http.StatusOK, []byte("This field is invalid")},
+		{"Short password", "admin", "admin", "admin@localhost", "pass", csrfToken, http.StatusOK, []byte("This field is too short (minimum is 10 characters)")},
+		{"Duplicate email", "dupe", "dupe", "dupe@example.com", "passwordadmin", csrfToken, http.StatusOK, []byte("Address is already in use")},
+		{"Empty username", "admin", "", "admin@localhost", "passwordadmin", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid username", "admin", "Admin!", "admin@localhost", "passwordadmin", csrfToken, http.StatusOK, []byte("Use 3 to 30 lowercase letters")},
+		{"Duplicate username", "admin", "taken", "admin@localhost", "passwordadmin", csrfToken, http.StatusOK, []byte("Username is already taken")},
+		{"Invalid CSRF Token", "", "", "", "", "wrongToken", http.StatusBadRequest, nil},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			form := url.Values{}
 			form.Add("name", tt.userName)
+			form.Add("username", tt.userUsername)
 			form.Add("email", tt.userEmail)
 			form.Add("password", tt.userPassword)
 			form.Add("csrf_token", tt.csrfToken)
@@ -192,3 +215,723 @@ func Test_createSnippetForm(t *testing.T) {
 	}
 
 }
+
+func Test_loginUser(t *testing.T) {
+	app := newTestApplication(t)
+	// The throttles run on a clock that only moves when a test case says so,
+	// so that a slow run can't outwait a delay.
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	app.accountThrottle.Now = clock
+	app.ipThrottle.Now = clock
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		userEmail string
+		wait      time.Duration
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Valid credentials", "alice@example.com", 0, http.StatusSeeOther, nil},
+		{"Invalid credentials", "bob@example.com", 0, http.StatusOK, []byte("Email or Password is incorrect")},
+		{"Retry too soon", "bob@example.com", 0, http.StatusOK, []byte("Too many failed login attempts. Please wait 1 second before trying again")},
+		{"Same IP, other account", "carol@example.com", 0, http.StatusOK, []byte("Too many failed login attempts")},
+		{"Retry after the delay", "bob@example.com", time.Second, http.StatusOK, []byte("Email or Password is incorrect")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.wait)
+			form := url.Values{}
+			form.Add("email", tt.userEmail)
+			form.Add("password", "wrongpassword")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/login", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contains %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// unreachable is a login backend that can't be reached.
+type unreachable struct{}
+
+func (unreachable) Authenticate(login, password string) (int, error) {
+	return 0, errors.New("dial tcp: connection refused")
+}
+
+func Test_loginUserBackendDown(t *testing.T) {
+	app := newTestApplication(t)
+	app.authenticator = auth.Chain{&mocks.UserModel{}, unreachable{}}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "bob@example.com")
+	form.Add("password", "wrongpassword")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusInternalServerError {
+		t.Errorf("want %d; got %d", http.StatusInternalServerError, code)
+	}
+	status, err := app.accountThrottle.Check("account:bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Failures != 1 {
+		t.Errorf("want the attempt to count as a failure; got %d failures", status.Failures)
+	}
+}
+
+func Test_userSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+
+	code, _, body := ts.get(t, "/user/sessions")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("Log out this session (current)")) {
+		t.Errorf("want body to list the current session")
+	}
+	if !bytes.Contains(body, []byte("Go HTTP client")) {
+		t.Errorf("want body to contain the device name")
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, header, _ := ts.postForm(t, "/user/sessions/revoke-all", form)
+	if code != http.StatusSeeOther || header.Get("Location") != "/" {
+		t.Errorf("want %d to %q; got %d to %q", http.StatusSeeOther, "/", code, header.Get("Location"))
+	}
+
+	code, header, _ = ts.get(t, "/user/profile")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to login after logging out everywhere; got %d to %q", code, header.Get("Location"))
+	}
+}
+
+func Test_closeAccount(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    string
+		snippets  string
+		password  string
+		wantCode  int
+		wantBody  []byte
+		wantFlash []byte
+	}{
+		{"Missing password", "deactivate", "", "", http.StatusOK, []byte("This field cannot be blank"), nil},
+		{"Invalid snippets choice", "delete", "keep", "pa$$word", http.StatusOK, []byte("This field is invalid"), nil},
+		{"Deactivate", "deactivate", "", "pa$$word", http.StatusSeeOther, nil, []byte("Your account has been deactivated")},
+		{"Delete", "delete", "anonymise", "pa$$word", http.StatusSeeOther, nil, []byte("Your account will be deleted on")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, "alice@example.com")
+			_, _, body := ts.get(t, "/user/close-account")
+
+			form := url.Values{}
+			form.Add("action", tt.action)
+			form.Add("snippets", tt.snippets)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/user/close-account", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+				code, header, _ := ts.get(t, "/user/profile")
+				if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+					t.Errorf("want to be logged out; got %d to %q", code, header.Get("Location"))
+				}
+			}
+		})
+	}
+}
+
+func Test_closeAccountWrongPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	app.authenticator = auth.Chain{}
+	_, _, body := ts.get(t, "/user/close-account")
+	form := url.Values{}
+	form.Add("action", "deactivate")
+	form.Add("password", "wrongpassword")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/user/close-account", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("Password is incorrect")) {
+		t.Errorf("want the password to be refused; got %d", code)
+	}
+	status, err := app.accountThrottle.Check("account:alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Failures != 1 {
+		t.Errorf("want the attempt to count as a failed login; got %d failures", status.Failures)
+	}
+}
+
+func Test_closeAccountSSO(t *testing.T) {
+	provider := oidctest.NewProvider("snippetbox", "s3cret")
+	defer provider.Close()
+
+	tests := []struct {
+		name        string
+		subject     string
+		wantFlash   []byte
+		wantClosed  bool
+		wantFailure int
+	}{
+		{"Linked identity", "248289761001", []byte("Your identity has been confirmed"), true, 0},
+		{"Other identity", "1000", []byte("Single sign-on did not confirm your account"), false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			app.oidc = oidc.New(oidc.Config{
+				Issuer:       provider.Issuer(),
+				ClientID:     "snippetbox",
+				ClientSecret: "s3cret",
+				RedirectURL:  ts.URL + "/user/login/oidc/callback",
+				Name:         "SSO",
+			})
+			provider.User.Subject = tt.subject
+
+			ts.login(t, "alice@example.com")
+			app.authenticator = auth.Chain{}
+			_, _, body := ts.get(t, "/user/close-account")
+			if !bytes.Contains(body, []byte(`<a href="/user/close-account/sso">Confirm with SSO instead</a>`)) {
+				t.Fatal("want the page to offer single sign-on")
+			}
+
+			_, header, _ := ts.get(t, "/user/close-account/sso")
+			rs, err := ts.Client().Get(header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+			code, header, _ := ts.get(t, strings.TrimPrefix(rs.Header.Get("Location"), ts.URL))
+			if code != http.StatusSeeOther || header.Get("Location") != "/user/close-account" {
+				t.Fatalf("want redirect to /user/close-account; got %d to %q", code, header.Get("Location"))
+			}
+
+			_, _, body = ts.get(t, "/user/close-account")
+			if !bytes.Contains(body, tt.wantFlash) {
+				t.Errorf("want body to contain %q", tt.wantFlash)
+			}
+
+			form := url.Values{}
+			form.Add("action", "deactivate")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, _, _ = ts.postForm(t, "/user/close-account", form)
+			if (code == http.StatusSeeOther) != tt.wantClosed {
+				t.Errorf("want closed %v; got %d", tt.wantClosed, code)
+			}
+
+			status, err := app.accountThrottle.Check("account:alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Failures != tt.wantFailure {
+				t.Errorf("want %d failed logins; got %d", tt.wantFailure, status.Failures)
+			}
+		})
+	}
+}
+
+func Test_oidcLogin(t *testing.T) {
+	provider := oidctest.NewProvider("snippetbox", "s3cret")
+	defer provider.Close()
+
+	tests := []struct {
+		name          string
+		email         string
+		emailVerified bool
+		wantLocation  string
+		wantAuth      bool
+	}{
+		{"Linked user", "alice@example.com", true, "/snippet/create", true},
+		{"Unverified email of existing user", "alice@example.com", false, "/user/login", false},
+		{"Refused user", "bob@example.com", true, "/user/login", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			app.oidc = oidc.New(oidc.Config{
+				Issuer:       provider.Issuer(),
+				ClientID:     "snippetbox",
+				ClientSecret: "s3cret",
+				RedirectURL:  ts.URL + "/user/login/oidc/callback",
+			})
+			provider.User.Email = tt.email
+			provider.User.EmailVerified = tt.emailVerified
+
+			_, _, body := ts.get(t, "/user/login")
+			if !bytes.Contains(body, []byte(`<a href="/user/login/oidc">Log in with SSO</a>`)) {
+				t.Fatal("want the login page to offer single sign-on")
+			}
+
+			code, header, _ := ts.get(t, "/user/login/oidc")
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+			}
+
+			// The fake provider logs the user in straight away and sends
+			// them back to the callback.
+			rs, err := ts.Client().Get(header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+			callback := rs.Header.Get("Location")
+			if !strings.HasPrefix(callback, ts.URL+"/user/login/oidc/callback?") {
+				t.Fatalf("want redirect to the callback; got %q", callback)
+			}
+
+			code, header, _ = ts.get(t, strings.TrimPrefix(callback, ts.URL))
+			if code != http.StatusSeeOther {
+				t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+
+			code, _, _ = ts.get(t, "/user/profile")
+			if (code == http.StatusOK) != tt.wantAuth {
+				t.Errorf("want authenticated %v; got profile status %d", tt.wantAuth, code)
+			}
+		})
+	}
+
+	t.Run("Bad code", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+		app.oidc = oidc.New(oidc.Config{
+			Issuer:       provider.Issuer(),
+			ClientID:     "snippetbox",
+			ClientSecret: "s3cret",
+			RedirectURL:  ts.URL + "/user/login/oidc/callback",
+		})
+
+		_, header, _ := ts.get(t, "/user/login/oidc")
+		authURL, err := url.Parse(header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := authURL.Query().Get("state")
+		code, header, _ := ts.get(t, "/user/login/oidc/callback?code=bogus&state="+url.QueryEscape(state))
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+			t.Fatalf("want redirect to /user/login; got %d to %q", code, header.Get("Location"))
+		}
+		_, _, body := ts.get(t, "/user/login")
+		if !bytes.Contains(body, []byte("Single sign-on failed, please try again")) {
+			t.Error("want a flash message")
+		}
+	})
+
+	t.Run("Forged state", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+		app.oidc = oidc.New(oidc.Config{Issuer: provider.Issuer(), ClientID: "snippetbox"})
+
+		code, _, _ := ts.get(t, "/user/login/oidc/callback?code=abc&state=forged")
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func Test_requestExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/user/profile")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/export", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	app.jobs.Wait()
+
+	_, _, body = ts.get(t, "/")
+	if !bytes.Contains(body, []byte("Notifications (1)")) {
+		t.Errorf("want an unread notification")
+	}
+
+	notifications, err := app.notifications.Latest(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].Link == "" {
+		t.Fatalf("want a notification with a download link; got %+v", notifications)
+	}
+	link := notifications[0].Link
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Signed link", link, http.StatusOK},
+		{"Tampered link", strings.Replace(link, "sig=", "sig=x", 1), http.StatusNotFound},
+		{"Unsigned link", strings.SplitN(link, "?", 2)[0], http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && header.Get("Content-Type") != "application/zip" {
+				t.Errorf("want %q; got %q", "application/zip", header.Get("Content-Type"))
+			}
+		})
+	}
+
+	t.Run("Pending", func(t *testing.T) {
+		before, err := app.notifications.CountUnread(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.exports.Start(1, "00000000000000000000000000000000.zip"); err != nil {
+			t.Fatal(err)
+		}
+		code, _, _ := ts.postForm(t, "/user/export", form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+		app.jobs.Wait()
+		_, _, body := ts.get(t, "/user/profile")
+		if !bytes.Contains(body, []byte("still preparing your last export")) {
+			t.Errorf("want the request refused")
+		}
+		if n, _ := app.notifications.CountUnread(1); n != before {
+			t.Errorf("want no new notification; got %d", n-before)
+		}
+	})
+
+	t.Run("Purged", func(t *testing.T) {
+		name := path.Base(strings.SplitN(link, "?", 2)[0])
+		if err := app.exports.Delete(name); err != nil {
+			t.Fatal(err)
+		}
+		code, _, _ := ts.get(t, link)
+		if code != http.StatusGone {
+			t.Errorf("want %d; got %d", http.StatusGone, code)
+		}
+		if err := app.deleteOldExports(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(app.exportDir, name)); !os.IsNotExist(err) {
+			t.Errorf("want the archive removed; got %v", err)
+		}
+	})
+}
+
+func Test_publicProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Public", "", "/u/alice", http.StatusOK, []byte("@alice")},
+		{"Snippets", "", "/u/alice", http.StatusOK, []byte(`<a href="/snippet/1">An old silent pond</a>`)},
+		{"Unknown", "", "/u/nobody", http.StatusNotFound, nil},
+		{"Hidden", "", "/u/root", http.StatusNotFound, nil},
+		{"Hidden, other user", "alice@example.com", "/u/root", http.StatusNotFound, nil},
+		{"Hidden, own profile", "root@example.com", "/u/root", http.StatusOK, []byte("@root")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_settings(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/user/settings")
+	if !bytes.Contains(body, []byte(`value='alice'`)) {
+		t.Errorf("want the form to show the current username")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		username string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", "alice2", http.StatusSeeOther, nil},
+		{"Invalid", "a", http.StatusOK, []byte("Use 3 to 30 lowercase letters")},
+		{"Taken", "taken", http.StatusOK, []byte("Username is already taken")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("username", tt.username)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/settings", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_starSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("Star (")) {
+		t.Errorf("want the star button on the snippet page")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Star", "/snippet/1/star", http.StatusSeeOther, nil},
+		{"Hidden snippet", "/snippet/3/star", http.StatusNotFound, nil},
+		{"Unknown snippet", "/snippet/2/star", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	_, _, body = ts.get(t, "/snippet/1")
+	if !bytes.Contains(body, []byte("Unstar")) {
+		t.Errorf("want the snippet to be starred")
+	}
+	_, _, body = ts.get(t, "/user/stars")
+	if !bytes.Contains(body, []byte(`<a href="/snippet/1">An old silent pond</a>`)) {
+		t.Errorf("want the starred snippet on the stars page")
+	}
+}
+
+func Test_forkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	code, _, body := ts.get(t, "/snippet/1/fork")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte(`<input type="hidden" name="forked_from" value="1">`)) {
+		t.Errorf("want the form to record the original snippet")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		forkedFrom string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Fork", "1", http.StatusSeeOther, nil},
+		{"Hidden original", "3", http.StatusOK, []byte("The snippet you forked no longer exists")},
+		{"Unknown original", "9", http.StatusOK, []byte("The snippet you forked no longer exists")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "An old silent pond")
+			form.Add("file_name.0", "haiku.txt")
+			form.Add("file_content.0", "A frog jumps in")
+			form.Add("expires", "7")
+			form.Add("forked_from", tt.forkedFrom)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_snippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Fork", "/snippet/4/diff", http.StatusOK, []byte(`<div class="insert">A frog jumps in</div>`)},
+		{"Not a fork", "/snippet/1/diff", http.StatusNotFound, nil},
+		{"Unknown snippet", "/snippet/9/diff", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_signupUserAntiSpam(t *testing.T) {
+	app := newTestApplication(t)
+	app.antispam = antispam.New([]byte("test"), antispam.NewMemoryStore(), map[string]antispam.Policy{
+		signupRoute: {Honeypot: true, Difficulty: 4},
+	})
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tokenRX := regexp.MustCompile(`name="antispam_token" value="([^"]+)"`)
+	challengeRX := regexp.MustCompile(`data-challenge="([^"]+)"`)
+
+	tests := []struct {
+		name     string
+		honeypot string
+		solve    bool
+		wantCode int
+	}{
+		{"Person", "", true, http.StatusSeeOther},
+		{"Honeypot", "http://spam.example.com", true, http.StatusOK},
+		{"No work", "", false, http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/user/signup")
+			if !bytes.Contains(body, []byte(`name="website"`)) {
+				t.Fatal("want a honeypot in the form")
+			}
+			token, challenge := tokenRX.FindSubmatch(body), challengeRX.FindSubmatch(body)
+			if token == nil || challenge == nil {
+				t.Fatal("want a token and a challenge in the form")
+			}
+
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("username", fmt.Sprintf("bob%d", i))
+			form.Add("email", fmt.Sprintf("bob%d@example.com", i))
+			form.Add("password", "validPa$$word")
+			form.Add("website", tt.honeypot)
+			form.Add("antispam_token", string(token[1]))
+			if tt.solve {
+				for n := 0; ; n++ {
+					if nonce := strconv.Itoa(n); antispam.Solves(string(challenge[1]), nonce, 4) {
+						form.Add("antispam_nonce", nonce)
+						break
+					}
+				}
+			}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && !bytes.Contains(body, []byte("check that you")) {
+				t.Errorf("want the form to say why it was rejected")
+			}
+		})
+	}
+}
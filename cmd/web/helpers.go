@@ -2,11 +2,21 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/throttle"
+
+	"github.com/go-chi/chi/v5"
 	"github.com/justinas/nosurf"
 )
 
@@ -37,14 +47,41 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 		app.serverError(w, fmt.Errorf("the template %s doest not exists", name))
 		return
 	}
+	td = app.addDefaultData(td, r)
+	if route, ok := antispamPages[name]; ok {
+		fields, err := app.antispam.Fields(route)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		td.AntiSpam = fields
+	}
 	buf := new(bytes.Buffer)
-	if err := ts.Execute(buf, app.addDefaultData(td, r)); err != nil {
+	if err := ts.Execute(buf, td); err != nil {
 		app.serverError(w, err)
 		return
 	}
 	buf.WriteTo(w)
 }
 
+// renderFragment renders a template that scripts fetch to update part of a
+// page. Unlike render, it doesn't add the default data, which would use up
+// the flash message.
+func (app *application) renderFragment(w http.ResponseWriter, name string, td *TemplateData) {
+	ts, ok := app.templateCache[name]
+	if !ok {
+		app.serverError(w, fmt.Errorf("the template %s doest not exists", name))
+		return
+	}
+	buf := new(bytes.Buffer)
+	if err := ts.Execute(buf, td); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
 func (app *application) addDefaultData(td *TemplateData, r *http.Request) *TemplateData {
 	if td == nil {
 		td = &TemplateData{}
@@ -52,10 +89,32 @@ func (app *application) addDefaultData(td *TemplateData, r *http.Request) *Templ
 	td.CSRFToken = nosurf.Token(r)
 	td.CurrentYear = time.Now().Year()
 	td.Flash = app.session.PopString(r, "flash")
+	td.AuthenticatedUser = app.authenticatedUser(r)
 	td.IsAuthenticated = app.isAuthenticated(r)
+	if td.AuthenticatedUser != nil {
+		n, err := app.notifications.CountUnread(td.AuthenticatedUser.ID)
+		if err != nil {
+			app.errorLog.Println(err)
+		}
+		td.UnreadCount = n
+	}
+	if app.oidc != nil {
+		td.OIDCName = app.oidc.Config.Name
+	}
 	return td
 }
 
+// authenticatedUser returns the logged in user, or nil.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(contextKeyUser).(*models.User)
+	return user
+}
+
+// can reports whether the logged in user has permission.
+func (app *application) can(r *http.Request, permission models.Permission) bool {
+	return app.authenticatedUser(r).Can(permission)
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(contextKeyIsAuthenticated).(bool)
 	if !ok {
@@ -64,3 +123,145 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 		return isAuthenticated
 	}
 }
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitKey returns whom a request counts against: the logged in user,
+// wherever they connect from, then the bearer token the request was made
+// with, or else the IP address. Tokens are hashed so they don't end up in the
+// rate limit store. Nothing checks a token here, so one made up to get a fresh
+// bucket still counts against the IP limit in front.
+func rateLimitKey(r *http.Request, user *models.User) string {
+	if user != nil {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && len(auth) > len("Bearer ") {
+		sum := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+		return "token:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + clientIP(r)
+}
+
+// ceilSeconds rounds d up to whole seconds, as the Retry-After and
+// RateLimit-Reset headers want.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// humanWait rounds d up to whole seconds, or whole minutes once it is longer
+// than a minute, so it can be shown to a user.
+func humanWait(d time.Duration) string {
+	if d > time.Minute {
+		m := int((d + time.Minute - 1) / time.Minute)
+		if m == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", m)
+	}
+	s := int((d + time.Second - 1) / time.Second)
+	if s <= 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", s)
+}
+
+// checkLoginThrottle reports whether a login for email may be attempted from
+// ip. The IP address is checked first, then the account.
+func (app *application) checkLoginThrottle(ip, email string) (throttle.Status, error) {
+	status, err := app.ipThrottle.Check("ip:" + ip)
+	if err != nil || !status.Allowed() {
+		return status, err
+	}
+	return app.accountThrottle.Check("account:" + email)
+}
+
+// failLogin records a failed login against both ip and email. The returned
+// status is locked if either of them is now locked.
+func (app *application) failLogin(ip, email string) (throttle.Status, error) {
+	ipStatus, err := app.ipThrottle.Fail("ip:" + ip)
+	if err != nil {
+		return throttle.Status{}, err
+	}
+	accountStatus, err := app.accountThrottle.Fail("account:" + email)
+	if err != nil {
+		return throttle.Status{}, err
+	}
+	if ipStatus.Locked && !accountStatus.Locked {
+		return ipStatus, nil
+	}
+	return accountStatus, nil
+}
+
+func throttleMessage(status throttle.Status) string {
+	if status.Locked {
+		return fmt.Sprintf("Too many failed login attempts. Login has been locked, please try again in %s", humanWait(status.Wait))
+	}
+	return fmt.Sprintf("Too many failed login attempts. Please wait %s before trying again", humanWait(status.Wait))
+}
+
+func setRememberMeCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberMeCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(rememberMeLifetime),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearRememberMeCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberMeCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// revokeRememberMe revokes the token family of the remember-me cookie sent
+// with r, if any, and clears the cookie.
+func (app *application) revokeRememberMe(w http.ResponseWriter, r *http.Request) error {
+	c, err := r.Cookie(rememberMeCookie)
+	if err != nil {
+		return nil
+	}
+	clearRememberMeCookie(w)
+	return app.rememberTokens.Revoke(c.Value)
+}
+
+// audit records a security-relevant event. actorID is the user who did it, or
+// zero if nobody is logged in. A failure to record the event is logged but
+// doesn't fail the request.
+func (app *application) audit(r *http.Request, actorID int, action string, details audit.Details) {
+	e := &audit.Event{
+		Time:      time.Now(),
+		Action:    action,
+		ActorID:   actorID,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   details,
+	}
+	if err := app.auditLog.Insert(e); err != nil {
+		app.errorLog.Printf("audit: recording %s: %v", action, err)
+	}
+}
+
+// pathParam returns the URL parameter key, unescaped. chi matches the escaped
+// path when it differs from the decoded one, so that it can contain slashes.
+func pathParam(r *http.Request, key string) (string, error) {
+	value := chi.URLParam(r, key)
+	if r.URL.RawPath == "" {
+		return value, nil
+	}
+	return url.PathUnescape(value)
+}
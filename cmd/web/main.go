@@ -1,84 +1,431 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/antispam"
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/auth"
+	"github.com/aesuhaendi/go-snippetbox/pkg/envelope"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models/mysql"
+	"github.com/aesuhaendi/go-snippetbox/pkg/oidc"
+	"github.com/aesuhaendi/go-snippetbox/pkg/ratelimit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/session"
+	"github.com/aesuhaendi/go-snippetbox/pkg/signedurl"
+	"github.com/aesuhaendi/go-snippetbox/pkg/throttle"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/golangcollege/sessions"
 )
 
 type contextKey string
 
-const contextKeyIsAuthenticated = contextKey("isAuthenticated")
+const (
+	contextKeyIsAuthenticated = contextKey("isAuthenticated")
+	contextKeyUser            = contextKey("user")
+)
+
+const (
+	rememberMeCookie   = "remember_me"
+	rememberMeLifetime = 30 * 24 * time.Hour
+	// rememberMeGrace is how long a rotated remember-me token is still
+	// accepted, for requests the browser sent before it got the new one.
+	rememberMeGrace = 10 * time.Second
+)
 
 type application struct {
-	debug    bool
-	errorLog *log.Logger
-	infoLog  *log.Logger
-	session  *sessions.Session
+	accountThrottle *throttle.Throttler
+	antispam        *antispam.Guard
+	auditLog        audit.Store
+	authenticator   auth.Authenticator
+	collections     interface {
+		Insert(int, string, string, string, string) (int, error)
+		Update(int, string, string, string) error
+		GetBySlug(string) (*models.Collection, error)
+		ByUser(int) ([]*models.Collection, error)
+		Unavailable(int) ([]*models.Snippet, error)
+		CountSnippets(int) (int, error)
+		AddSnippet(int, int) error
+		RemoveSnippet(int, int) error
+		Reorder(int, []int) error
+		Delete(int) error
+	}
+	comments interface {
+		Insert(int, int, int, string, int, int, string) (int, error)
+		Get(int) (*models.Comment, error)
+		BySnippet(int) ([]*models.Comment, error)
+		Update(int, string) error
+		SetHidden(int, bool) error
+		Tombstone(int) (bool, error)
+		Delete(int) error
+	}
+	debug      bool
+	errorLog   *log.Logger
+	identities interface {
+		Authenticate(string, string, string, string, bool) (int, error)
+		UserID(string, string) (int, error)
+	}
+	exportDir string
+	exports   interface {
+		Start(int, string) error
+		Finish(string) error
+		Get(string) (*models.Export, error)
+		Delete(string) error
+		DeleteExpired(time.Duration) (int, error)
+	}
+	infoLog       *log.Logger
+	ipThrottle    *throttle.Throttler
+	jobs          sync.WaitGroup
+	notifications interface {
+		Insert(int, string, string) error
+		Latest(int) ([]*models.Notification, error)
+		CountUnread(int) (int, error)
+		MarkAllRead(int) error
+	}
+	oidc           *oidc.Client
+	rateLimiter    *ratelimit.Limiter
+	rateLimits     map[string]ratelimit.Policy
+	rememberTokens interface {
+		Issue(int, time.Time) (string, error)
+		Rotate(string, time.Time) (int, string, error)
+		Revoke(string) error
+		RevokeAll(int) error
+	}
+	reports interface {
+		Insert(int, int, string, string) (int, error)
+		CountSince(int, time.Time) (int, error)
+		Get(int) (*models.Report, error)
+		Open(int, int) ([]*models.Report, error)
+		Resolve(int, string, int) ([]*models.Report, error)
+	}
+	session  *session.Session
+	signer   *signedurl.Signer
 	snippets interface {
-		Insert(string, string, string) (int, error)
+		Insert(int, int, string, []*models.File, []string, string) (int, error)
+		Update(int, string, []*models.File, []string) error
 		Get(int) (*models.Snippet, error)
+		GetAny(int) (*models.Snippet, error)
+		Revisions(int) ([]*models.Revision, error)
 		Latest() ([]*models.Snippet, error)
+		ByUser(int) ([]*models.Snippet, error)
+		PublicByUser(int, int, int) ([]*models.Snippet, error)
+		Search(string, int, int) ([]*models.Snippet, error)
+		Count() (*models.SnippetCounts, error)
+		SetHidden(int, bool) error
+		Delete(int) error
+	}
+	stars interface {
+		Toggle(int, int) (bool, error)
+		Starred(int, int) (bool, error)
+		ByUser(int) ([]*models.Snippet, error)
+		MostStarred(time.Time, int) ([]*models.Snippet, error)
+	}
+	tags interface {
+		Snippets(string, int, int) ([]*models.Snippet, error)
+		Popular(int) ([]*models.Tag, error)
 	}
 	templateCache map[string]*template.Template
 	users         interface {
-		Insert(string, string, string) error
-		Authenticate(string, string) (int, error)
+		Insert(string, string, string, string) error
 		Get(int) (*models.User, error)
+		GetByUsername(string) (*models.User, error)
+		UpdateProfile(int, string, bool) error
+		Search(string, int, int) ([]*models.User, error)
+		Count() (*models.UserCounts, error)
 		ChangePassword(int, string, string) error
+		SetRole(int, string) error
+		SetActive(int, bool) error
+		RequirePasswordReset(int) error
+		Deactivate(int) error
+		ScheduleDeletion(int, time.Time, bool) error
 	}
 }
 
+// accountDeletionGracePeriod is how long a user has to change their mind,
+// by logging in again, before their account is deleted for good.
+const accountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// reauthLifetime is how long confirming who you are through single sign-on
+// lets you close your account without a password.
+const reauthLifetime = 5 * time.Minute
+
+// masterKeysEnv holds the master keys when no -master-keys file is given.
+const masterKeysEnv = "SNIPPETBOX_MASTER_KEYS"
+
+// An account is locked after a handful of failures, while an IP address, which
+// may be shared by many users, gets more room before it is locked out.
+var (
+	accountThrottlePolicy = throttle.Policy{
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		MaxFailures:     5,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	}
+	ipThrottlePolicy = throttle.Policy{
+		BaseDelay:       250 * time.Millisecond,
+		MaxDelay:        30 * time.Second,
+		MaxFailures:     20,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	}
+)
+
+// rateLimitPolicies are the budgets of the route groups in routes.go. Logging
+// in and signing up are limited tightly, to slow down password guessing and
+// bulk signups, while reading is generous enough for anyone browsing.
+// Previews also count as writes, and refill slower, so that previewing can't
+// keep the writes bucket empty. Every request also counts against its IP
+// address, before the session is loaded; that budget leaves room for a few
+// users behind one address.
+var rateLimitPolicies = map[string]ratelimit.Policy{
+	"auth":    {Burst: 10, Refill: 6 * time.Second},
+	"ip":      {Burst: 600, Refill: 50 * time.Millisecond},
+	"preview": {Burst: 20, Refill: 3 * time.Second},
+	"read":    {Burst: 120, Refill: 250 * time.Millisecond},
+	"write":   {Burst: 30, Refill: 2 * time.Second},
+}
+
+// antispamPolicies protect the forms that bots target, keyed by the route
+// the form posts to. Signing up costs a bot more work than posting, which
+// people do more often. A -antispam-config file can replace the policy of
+// any of these routes.
+var antispamPolicies = map[string]antispam.Policy{
+	signupRoute:        {Honeypot: true, MinFillTime: 3 * time.Second, Difficulty: 16},
+	createSnippetRoute: {Honeypot: true, MinFillTime: 2 * time.Second, Difficulty: 12},
+}
+
 func main() {
 	var addr string
 	flag.StringVar(&addr, "addr", ":4000", "HTTP network address")
 	var dsn string
 	flag.StringVar(&dsn, "dsn", "root:@(localhost:3306)/snippetbox?parseTime=true&charset=utf8mb4,utf8", "MySQL Data source name")
-	var secret string
-	flag.StringVar(&secret, "secret", "123abcdefghijklmnopqrstuvwxyz123", "Secret key - 32 Chars")
 	var debug bool
 	flag.BoolVar(&debug, "debug", false, "Enable debug Mode")
+	var throttleStore string
+	flag.StringVar(&throttleStore, "throttle-store", "memory", "Failed login, rate limit and used antispam token store - memory or mysql")
+	var sessionStore string
+	flag.StringVar(&sessionStore, "session-store", "mysql", "Session store - memory or mysql")
+	var antispamConfig string
+	flag.StringVar(&antispamConfig, "antispam-config", "", "Path to a JSON file of antispam policies by route")
+	var oidcConfig string
+	flag.StringVar(&oidcConfig, "oidc-config", "", "Path to an OpenID Connect JSON config file")
+	var oidcFlags oidc.Config
+	flag.StringVar(&oidcFlags.Name, "oidc-name", "", "OpenID Connect provider name shown on the login page")
+	flag.StringVar(&oidcFlags.Issuer, "oidc-issuer", "", "OpenID Connect issuer URL - enables single sign-on")
+	flag.StringVar(&oidcFlags.ClientID, "oidc-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&oidcFlags.ClientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	flag.StringVar(&oidcFlags.RedirectURL, "oidc-redirect-url", "", "OpenID Connect redirect URL (default https://localhost<addr>/user/login/oidc/callback)")
+	var exportDir string
+	flag.StringVar(&exportDir, "export-dir", "./exports", "Directory where personal data exports are kept until they expire")
+	var masterKeys string
+	flag.StringVar(&masterKeys, "master-keys", "", "File of base64 master keys, current first, that encrypt snippet content (default $"+masterKeysEnv+", or no encryption)")
+	var signingKey string
+	flag.StringVar(&signingKey, "signing-key", "", "Key for signing download links (required unless -debug is set)")
+	var ldapFlags auth.LDAP
+	flag.StringVar(&ldapFlags.URL, "ldap-url", "", "LDAP server URL, e.g. ldaps://ldap.example.com - enables directory logins")
+	flag.BoolVar(&ldapFlags.StartTLS, "ldap-start-tls", false, "Upgrade ldap:// connections with StartTLS")
+	flag.StringVar(&ldapFlags.BindDN, "ldap-bind-dn", "", "LDAP service account DN used to search for users")
+	flag.StringVar(&ldapFlags.BindPassword, "ldap-bind-password", "", "LDAP service account password")
+	flag.StringVar(&ldapFlags.BaseDN, "ldap-base-dn", "", "LDAP base DN to search for users")
+	flag.StringVar(&ldapFlags.Filter, "ldap-filter", "(|(uid=%s)(mail=%s))", "LDAP filter finding a user, %s is replaced by the login")
+	flag.BoolVar(&ldapFlags.LinkByEmail, "ldap-link-by-email", false, "Link LDAP users to existing local accounts with the same email")
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stdout, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile) // Default: os.Stderr
 
+	oidcClient, err := newOIDCClient(oidcConfig, oidcFlags, addr)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	db, err := openDB(dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	defer db.Close()
 
+	keys, err := envelope.Load(masterKeys, masterKeysEnv)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if keys == nil {
+		infoLog.Print("no master keys given, snippet content is stored unencrypted")
+	}
+
 	templateCache, err := newTemplateCache("./ui/html")
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
-	session := sessions.New([]byte(secret))
-	session.Lifetime = 12 * time.Hour
-	session.Secure = true
+	var sessions session.Store
+	switch sessionStore {
+	case "memory":
+		sessions = session.NewMemoryStore()
+	case "mysql":
+		sessions = &mysql.SessionModel{DB: db}
+	default:
+		errorLog.Fatalf("unknown session store %q", sessionStore)
+	}
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := sessions.DeleteExpired(); err != nil {
+				errorLog.Println(err)
+			}
+		}
+	}()
+
+	sess := session.New(sessions)
+	sess.Lifetime = 12 * time.Hour
+	sess.Cookie.Secure = true
+	sess.UserIDKey = "authenticatedUserID"
+
+	users := &mysql.UserModel{DB: db}
+	identities := &mysql.IdentityModel{DB: db}
+	go func() {
+		for range time.Tick(time.Hour) {
+			n, err := users.PurgeDeleted()
+			if err != nil {
+				errorLog.Println(err)
+			}
+			if n > 0 {
+				infoLog.Printf("deleted %d accounts whose grace period has ended", n)
+			}
+		}
+	}()
+
+	rememberTokens := &mysql.RememberTokenModel{DB: db, Grace: rememberMeGrace}
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := rememberTokens.DeleteExpired(); err != nil {
+				errorLog.Println(err)
+			}
+		}
+	}()
+
+	authenticator := auth.Chain{users}
+	if ldapFlags.URL != "" {
+		ldap := auth.NewLDAP(ldapFlags.URL, ldapFlags.BaseDN, identities)
+		ldap.StartTLS = ldapFlags.StartTLS
+		ldap.BindDN = ldapFlags.BindDN
+		ldap.BindPassword = ldapFlags.BindPassword
+		ldap.Filter = ldapFlags.Filter
+		ldap.LinkByEmail = ldapFlags.LinkByEmail
+		authenticator = append(authenticator, ldap)
+	}
+
+	var attempts throttle.Store
+	var buckets ratelimit.Store
+	var usedTokens antispam.Store
+	switch throttleStore {
+	case "memory":
+		attempts = throttle.NewMemoryStore()
+		buckets = ratelimit.NewMemoryStore()
+		usedTokens = antispam.NewMemoryStore()
+	case "mysql":
+		attempts = &mysql.LoginAttemptModel{DB: db}
+		rateLimits := &mysql.RateLimitModel{DB: db}
+		buckets = rateLimits
+		antispamTokens := &mysql.AntispamTokenModel{DB: db}
+		usedTokens = antispamTokens
+		go func() {
+			for range time.Tick(time.Hour) {
+				if _, err := rateLimits.DeleteExpired(); err != nil {
+					errorLog.Println(err)
+				}
+				if _, err := antispamTokens.DeleteExpired(); err != nil {
+					errorLog.Println(err)
+				}
+			}
+		}()
+	default:
+		errorLog.Fatalf("unknown throttle store %q", throttleStore)
+	}
+
+	if antispamConfig != "" {
+		policies, err := antispam.LoadPolicies(antispamConfig)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		for route, p := range policies {
+			if _, ok := antispamPolicies[route]; !ok {
+				errorLog.Fatalf("antispam: %s: no protected form posts to %q", antispamConfig, route)
+			}
+			antispamPolicies[route] = p
+		}
+	}
+
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		errorLog.Fatal(err)
+	}
+	key := []byte(signingKey)
+	if signingKey == "" {
+		// A random key would break every download link on restart, so only
+		// allow one while debugging.
+		if !debug {
+			errorLog.Fatal("-signing-key is required unless -debug is set")
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			errorLog.Fatal(err)
+		}
+	}
 
 	app := &application{
-		debug:         debug,
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		snippets:      &mysql.SnippetModel{DB: db},
-		templateCache: templateCache,
-		users:         &mysql.UserModel{DB: db},
+		accountThrottle: throttle.New(attempts, accountThrottlePolicy),
+		antispam:        antispam.New(key, usedTokens, antispamPolicies),
+		auditLog:        &mysql.AuditEventModel{DB: db},
+		authenticator:   authenticator,
+		collections:     &mysql.CollectionModel{DB: db, Keys: keys},
+		comments:        &mysql.CommentModel{DB: db},
+		debug:           debug,
+		errorLog:        errorLog,
+		exportDir:       exportDir,
+		exports:         &mysql.ExportModel{DB: db},
+		identities:      identities,
+		infoLog:         infoLog,
+		ipThrottle:      throttle.New(attempts, ipThrottlePolicy),
+		notifications:   &mysql.NotificationModel{DB: db},
+		oidc:            oidcClient,
+		rateLimiter:     ratelimit.New(buckets),
+		rateLimits:      rateLimitPolicies,
+		rememberTokens:  rememberTokens,
+		reports:         &mysql.ReportModel{DB: db},
+		session:         sess,
+		signer:          signedurl.New(key),
+		snippets:        &mysql.SnippetModel{DB: db, Keys: keys},
+		stars:           &mysql.StarModel{DB: db},
+		tags:            &mysql.TagModel{DB: db},
+		templateCache:   templateCache,
+		users:           users,
 	}
 
+	sess.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		app.serverError(w, err)
+	}
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := app.deleteOldExports(); err != nil {
+				errorLog.Println(err)
+			}
+		}
+	}()
+
 	tlsConfig := &tls.Config{
 		PreferServerCipherSuites: true,
 		CurvePreferences:         []tls.CurveID{tls.X25519, tls.CurveP256},
@@ -94,10 +441,27 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// On SIGINT or SIGTERM, stop taking requests and let the requests and
+	// background jobs, such as data exports, in flight finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("shutting down")
+		c, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdown <- srv.Shutdown(c)
+	}()
+
 	infoLog.Printf("Listening on https://localhost%s\n", addr)
-	if err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"); err != nil {
+	if err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"); !errors.Is(err, http.ErrServerClosed) {
 		errorLog.Fatal(err)
 	}
+	if err := <-shutdown; err != nil {
+		errorLog.Println(err)
+	}
+	app.jobs.Wait()
 }
 
 func openDB(dsn string) (*sql.DB, error) {
@@ -110,3 +474,38 @@ func openDB(dsn string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// newOIDCClient builds the single sign-on client from the config file at path,
+// if any, with non-empty flags taking precedence. It returns nil if no issuer
+// is configured.
+func newOIDCClient(path string, flags oidc.Config, addr string) (*oidc.Client, error) {
+	cfg := &oidc.Config{}
+	if path != "" {
+		var err error
+		cfg, err = oidc.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&cfg.Name, flags.Name},
+		{&cfg.Issuer, flags.Issuer},
+		{&cfg.ClientID, flags.ClientID},
+		{&cfg.ClientSecret, flags.ClientSecret},
+		{&cfg.RedirectURL, flags.RedirectURL},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = "https://localhost" + addr + "/user/login/oidc/callback"
+	}
+	if cfg.Issuer == "" {
+		return nil, nil
+	}
+	return oidc.New(*cfg), nil
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
 
 	"github.com/justinas/nosurf"
@@ -58,12 +61,76 @@ func requireAuthentication(app *application) Middleware {
 				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 				return
 			}
+			// An admin may have forced a password reset, in which case the
+			// user can't do anything else until they have chosen a new one.
+			if app.authenticatedUser(r).PasswordResetRequired && r.URL.Path != "/user/change-password" && r.URL.Path != "/user/logout" {
+				app.session.Put(r, "flash", "You must choose a new password before continuing")
+				http.Redirect(w, r, "/user/change-password", http.StatusSeeOther)
+				return
+			}
 			w.Header().Add("Cache-Control", "no-store")
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// requireRole must come after requireAuthentication. It answers 403 Forbidden
+// unless the user has role or a role above it.
+func requireRole(app *application, role string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.authenticatedUser(r).HasRole(role) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rememberMe logs the user back in from the remember-me cookie once their
+// session has expired. The token is rotated on every use.
+func rememberMe(app *application) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.session.Exists(r, "authenticatedUserID") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c, err := r.Cookie(rememberMeCookie)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, token, err := app.rememberTokens.Rotate(c.Value, time.Now().Add(rememberMeLifetime))
+			if errors.Is(err, models.ErrTokenReused) {
+				app.infoLog.Printf("remember-me token reused for user %d from %s, all of its tokens have been revoked", id, clientIP(r))
+				clearRememberMeCookie(w)
+				next.ServeHTTP(w, r)
+				return
+			} else if errors.Is(err, models.ErrInvalidToken) {
+				clearRememberMeCookie(w)
+				next.ServeHTTP(w, r)
+				return
+			} else if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			// No token means the old one was used moments ago, and the
+			// browser already has its replacement.
+			if token != "" {
+				setRememberMeCookie(w, token)
+			}
+			app.session.RenewToken(r)
+			app.session.Put(r, "authenticatedUserID", id)
+			app.audit(r, id, audit.ActionLogin, audit.Details{"method": "remember_me"})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func authenticate(app *application) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -84,6 +151,7 @@ func authenticate(app *application) Middleware {
 			}
 
 			ctx := context.WithValue(r.Context(), contextKeyIsAuthenticated, true)
+			ctx = context.WithValue(ctx, contextKeyUser, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -98,3 +166,66 @@ func noSurf(next http.Handler) http.Handler {
 	})
 	return csrfHandler
 }
+
+// rateLimit limits requests with the policy of group, and answers 429 Too
+// Many Requests once the client's bucket is empty. Groups without a policy
+// aren't limited. Requests count against the user once authenticate has run,
+// and against their IP address or API token before.
+func rateLimit(app *application, group string) Middleware {
+	return rateLimitBy(app, group, func(r *http.Request) string {
+		return rateLimitKey(r, app.authenticatedUser(r))
+	})
+}
+
+// rateLimitBy is rateLimit with the bucket of a request chosen by key.
+func rateLimitBy(app *application, group string, key func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy, ok := app.rateLimits[group]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			res, err := app.rateLimiter.Allow(group+":"+key(r), policy)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitIPs limits every request by IP address with the "ip" policy. It comes
+// before the session is loaded, so that a flood doesn't reach the session and
+// remember-me stores.
+func limitIPs(app *application) Middleware {
+	return rateLimitBy(app, "ip", func(r *http.Request) string {
+		return "ip:" + clientIP(r)
+	})
+}
+
+// limitRequests limits reads with the "read" policy and everything else with
+// the stricter "write" policy.
+func limitRequests(app *application) Middleware {
+	read, write := rateLimit(app, "read"), rateLimit(app, "write")
+	return func(next http.Handler) http.Handler {
+		readNext, writeNext := read(next), write(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				readNext.ServeHTTP(w, r)
+			default:
+				writeNext.ServeHTTP(w, r)
+			}
+		})
+	}
+}
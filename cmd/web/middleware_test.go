@@ -4,7 +4,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/ratelimit"
 )
 
 func Test_secureHeaders(t *testing.T) {
@@ -47,3 +51,159 @@ func Test_secureHeaders(t *testing.T) {
 		t.Errorf("want body to equal %q", "OK")
 	}
 }
+
+func Test_rememberMe(t *testing.T) {
+	tests := []struct {
+		name               string
+		token              string
+		wantCode           int
+		wantHeaderLocation string
+		wantCookie         string
+	}{
+		{"Valid token", "selector:validator", http.StatusOK, "", "selector2:validator2"},
+		{"Just rotated token", "rotated:validator", http.StatusOK, "", "rotated:validator"},
+		{"Reused token", "stolen:validator", http.StatusSeeOther, "/user/login", ""},
+		{"Unknown token", "unknown:validator", http.StatusSeeOther, "/user/login", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			u, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts.Client().Jar.SetCookies(u, []*http.Cookie{{Name: rememberMeCookie, Value: tt.token}})
+
+			code, header, _ := ts.get(t, "/user/profile")
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantHeaderLocation {
+				t.Errorf("want %q; got %q", tt.wantHeaderLocation, loc)
+			}
+
+			cookie := ""
+			for _, c := range ts.Client().Jar.Cookies(u) {
+				if c.Name == rememberMeCookie {
+					cookie = c.Value
+				}
+			}
+			if cookie != tt.wantCookie {
+				t.Errorf("want cookie %q; got %q", tt.wantCookie, cookie)
+			}
+		})
+	}
+}
+
+func Test_rateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = map[string]ratelimit.Policy{
+		"auth": {Burst: 2, Refill: time.Hour},
+		"read": {Burst: 3, Refill: time.Minute},
+	}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The login page counts against both the auth and the read buckets, and
+	// its headers describe the auth bucket.
+	tests := []struct {
+		name          string
+		urlPath       string
+		wantCode      int
+		wantLimit     string
+		wantRemaining string
+		wantRetry     string
+	}{
+		{"First login", "/user/login", http.StatusOK, "2", "1", ""},
+		{"Second login", "/user/login", http.StatusOK, "2", "0", ""},
+		{"Third login", "/user/login", http.StatusTooManyRequests, "2", "0", "3600"},
+		{"Read", "/about", http.StatusTooManyRequests, "3", "0", "60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			for name, want := range map[string]string{
+				"RateLimit-Limit":     tt.wantLimit,
+				"RateLimit-Remaining": tt.wantRemaining,
+				"Retry-After":         tt.wantRetry,
+			} {
+				if got := header.Get(name); got != want {
+					t.Errorf("want %s %q; got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_limitIPs(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = map[string]ratelimit.Policy{"ip": {Burst: 1, Refill: time.Hour}}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if code, _, _ := ts.get(t, "/about"); code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	// A limited request must not get as far as rotating the remember-me
+	// token.
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar.SetCookies(u, []*http.Cookie{{Name: rememberMeCookie, Value: "selector:validator"}})
+	if code, _, _ := ts.get(t, "/user/profile"); code != http.StatusTooManyRequests {
+		t.Errorf("want %d; got %d", http.StatusTooManyRequests, code)
+	}
+	for _, c := range ts.Client().Jar.Cookies(u) {
+		if c.Name == rememberMeCookie && c.Value != "selector:validator" {
+			t.Errorf("want the token left alone; got %q", c.Value)
+		}
+	}
+}
+
+func Test_rateLimitToken(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = map[string]ratelimit.Policy{"read": {Burst: 1, Refill: time.Hour}}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{"First token", "first", http.StatusOK},
+		{"First token again", "first", http.StatusTooManyRequests},
+		{"Second token", "second", http.StatusOK},
+		{"No token", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/about", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+		})
+	}
+}
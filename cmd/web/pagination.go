@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxPage is the last page that can be asked for. Later pages are clamped to
+// it, so that the offset can't overflow.
+const maxPage = 10000
+
+// Pagination tracks which page of a listing is shown. Handlers fetch
+// Limit() rows, one more than PerPage, and pass the count to SetResults so
+// that we know whether there is a next page without counting everything.
+type Pagination struct {
+	Page    int
+	PerPage int
+	HasNext bool
+
+	path  string
+	query url.Values
+}
+
+func newPagination(r *http.Request, perPage int) *Pagination {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	} else if page > maxPage {
+		page = maxPage
+	}
+	return &Pagination{
+		Page:    page,
+		PerPage: perPage,
+		path:    r.URL.Path,
+		query:   r.URL.Query(),
+	}
+}
+
+func (p *Pagination) Limit() int {
+	return p.PerPage + 1
+}
+
+func (p *Pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// SetResults records whether there is another page and returns how many of
+// the n fetched rows should be shown.
+func (p *Pagination) SetResults(n int) int {
+	p.HasNext = n > p.PerPage
+	if p.HasNext {
+		return p.PerPage
+	}
+	return n
+}
+
+func (p *Pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p *Pagination) PrevURL() string {
+	return p.url(p.Page - 1)
+}
+
+func (p *Pagination) NextURL() string {
+	return p.url(p.Page + 1)
+}
+
+func (p *Pagination) url(page int) string {
+	q := url.Values{}
+	for k, v := range p.query {
+		q[k] = v
+	}
+	q.Set("page", strconv.Itoa(page))
+	return p.path + "?" + q.Encode()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_newPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantPage int
+	}{
+		{"Missing", "", 1},
+		{"Valid", "?page=3", 3},
+		{"Negative", "?page=-2", 1},
+		{"Not a number", "?page=x", 1},
+		{"Too large", "?page=9223372036854775807", maxPage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/search"+tt.query, nil)
+			p := newPagination(r, 20)
+			if p.Page != tt.wantPage {
+				t.Errorf("want page %d; got %d", tt.wantPage, p.Page)
+			}
+			if p.Offset() < 0 {
+				t.Errorf("want a non-negative offset; got %d", p.Offset())
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// A user can send maxReports reports per reportWindow, which is plenty for
+// honest use but keeps the queue from being flooded.
+const (
+	maxReports       = 5
+	reportWindow     = time.Hour
+	maxReportDetails = 500
+)
+
+// reportSnippet puts a report about a snippet in the moderation queue.
+func (app *application) reportSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("reason")
+	form.PermittedValues("reason", models.ReportReasons...)
+	form.MaxLength("details", maxReportDetails)
+	user := app.authenticatedUser(r)
+	if !form.Valid() || s.UserID == user.ID {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	n, err := app.reports.CountSince(user.ID, time.Now().Add(-reportWindow))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if n >= maxReports {
+		app.session.Put(r, "flash", "You've sent a lot of reports recently. Please try again later.")
+		http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+		return
+	}
+
+	id, err := app.reports.Insert(s.ID, user.ID, form.Get("reason"), strings.TrimSpace(form.Get("details")))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, user.ID, audit.ActionSnippetReport, audit.Details{"snippet_id": s.ID, "report_id": id, "reason": form.Get("reason")})
+	app.session.Put(r, "flash", "Thanks, a moderator will review your report")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// adminReports shows the moderation queue, oldest reports first.
+func (app *application) adminReports(w http.ResponseWriter, r *http.Request) {
+	page := newPagination(r, adminPerPage)
+	reports, err := app.reports.Open(page.Limit(), page.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin-reports.page.tmpl", &TemplateData{
+		Pagination: page,
+		Reports:    reports[:page.SetResults(len(reports))],
+	})
+}
+
+// adminReport loads the report named in the URL. It writes the error
+// response and returns nil if there is no such report.
+func (app *application) adminReport(w http.ResponseWriter, r *http.Request) *models.Report {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	report, err := app.reports.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return report
+}
+
+// adminResolveReport returns a handler that resolves a report with status:
+// it hides the snippet, deactivating its author too if asked, or dismisses
+// the report. Every open report on the snippet is resolved at once, and the
+// reporters are told the outcome.
+func (app *application) adminResolveReport(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := app.adminReport(w, r)
+		if report == nil {
+			return
+		}
+		if report.Status != models.ReportOpen {
+			app.session.Put(r, "flash", "That report has already been resolved")
+			http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+			return
+		}
+
+		moderatorID := app.authenticatedUser(r).ID
+		details := audit.Details{"report_id": report.ID}
+		switch status {
+		case models.ReportDeactivated:
+			author, err := app.users.Get(report.AuthorID)
+			if errors.Is(err, models.ErrNoRecord) {
+				app.clientError(w, http.StatusBadRequest)
+				return
+			} else if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			if author.ID == moderatorID || (author.HasRole(models.RoleModerator) && !app.can(r, models.PermManageUsers)) {
+				app.session.Put(r, "flash", "You can't deactivate "+author.Name+" from here")
+				http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+				return
+			}
+			if err := app.users.SetActive(author.ID, false); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			if err := app.logoutEverywhere(author.ID); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.audit(r, moderatorID, audit.ActionAdminDeactivate, audit.Details{"user_id": author.ID, "report_id": report.ID})
+			fallthrough
+		case models.ReportHidden:
+			if err := app.snippets.SetHidden(report.SnippetID, true); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			details["snippet_id"] = report.SnippetID
+			app.audit(r, moderatorID, audit.ActionAdminHide, details)
+		case models.ReportDismissed:
+			details["snippet_id"] = report.SnippetID
+			app.audit(r, moderatorID, audit.ActionAdminDismiss, details)
+		}
+
+		resolved, err := app.reports.Resolve(report.SnippetID, status, moderatorID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		for _, rep := range resolved {
+			message, link := reportOutcome(rep)
+			if err := app.notifications.Insert(rep.ReporterID, message, link); err != nil {
+				app.errorLog.Printf("notifying reporter #%d: %v", rep.ReporterID, err)
+			}
+		}
+
+		app.session.Put(r, "flash", fmt.Sprintf("The reports on snippet #%d have been resolved", report.SnippetID))
+		http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+	}
+}
+
+// reportOutcome returns the notification telling a reporter how their report
+// was resolved. Hidden snippets aren't linked, as the reporter can't see them.
+func reportOutcome(report *models.Report) (message, link string) {
+	prefix := fmt.Sprintf("Thanks for reporting snippet #%d. ", report.SnippetID)
+	switch report.Status {
+	case models.ReportHidden:
+		return prefix + "A moderator has hidden it.", ""
+	case models.ReportDeactivated:
+		return prefix + "A moderator has hidden it and deactivated its author's account.", ""
+	default:
+		return prefix + "A moderator reviewed it and found that it doesn't break the rules.", fmt.Sprintf("/snippet/%d", report.SnippetID)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_reportSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/1")
+	if want := []byte(`<form action="/snippet/1/report" method="POST">`); !bytes.Contains(body, want) {
+		t.Errorf("want body to contain %q", want)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		reason    string
+		wantCode  int
+		wantFlash []byte
+	}{
+		{"Report", "/snippet/1/report", models.ReasonSpam, http.StatusSeeOther, []byte("a moderator will review your report")},
+		{"Own snippet", "/snippet/4/report", models.ReasonSpam, http.StatusBadRequest, nil},
+		{"No reason", "/snippet/1/report", "", http.StatusBadRequest, nil},
+		{"Unknown reason", "/snippet/1/report", "boring", http.StatusBadRequest, nil},
+		{"Hidden snippet", "/snippet/3/report", models.ReasonAbuse, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("reason", tt.reason)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/snippet/1")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+
+	t.Run("Rate limit", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("reason", models.ReasonOther)
+		form.Add("csrf_token", csrfToken)
+		for i := 1; i < maxReports; i++ {
+			ts.postForm(t, "/snippet/1/report", form)
+		}
+		ts.postForm(t, "/snippet/1/report", form)
+		_, _, body := ts.get(t, "/snippet/1")
+		if want := []byte("a lot of reports recently"); !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+		reports, _ := app.reports.Open(100, 0)
+		if len(reports) != maxReports {
+			t.Errorf("want %d reports; got %d", maxReports, len(reports))
+		}
+	})
+}
+
+func Test_adminResolveReport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	app.reports.Insert(1, 1, models.ReasonSpam, "")
+	app.reports.Insert(1, 2, models.ReasonAbuse, "Rude")
+	app.reports.Insert(4, 2, models.ReasonMalware, "")
+
+	ts.login(t, "alice@example.com")
+	code, _, _ := ts.get(t, "/admin/reports")
+	if code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	ts.login(t, "root@example.com")
+	code, _, body := ts.get(t, "/admin/reports")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range [][]byte{[]byte("#1 An old silent pond"), []byte("<small>Rude</small>"), []byte(`action="/admin/reports/3/deactivate"`)} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+	if want := []byte(`action="/admin/reports/1/deactivate"`); bytes.Contains(body, want) {
+		t.Errorf("want no way to deactivate the author of an anonymous snippet")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash []byte
+	}{
+		{"Dismiss", "/admin/reports/1/dismiss", http.StatusSeeOther, []byte("The reports on snippet #1 have been resolved")},
+		{"Already resolved", "/admin/reports/2/hide", http.StatusSeeOther, []byte("That report has already been resolved")},
+		{"Deactivate", "/admin/reports/3/deactivate", http.StatusSeeOther, []byte("The reports on snippet #4 have been resolved")},
+		{"Unknown report", "/admin/reports/9/hide", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantFlash != nil {
+				_, _, body := ts.get(t, "/admin/reports")
+				if !bytes.Contains(body, tt.wantFlash) {
+					t.Errorf("want body to contain %q", tt.wantFlash)
+				}
+			}
+		})
+	}
+
+	notifications, err := app.notifications.Latest(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("want 2 notifications; got %d", len(notifications))
+	}
+	if !strings.Contains(notifications[0].Message, "deactivated its author") || notifications[0].Link != "" {
+		t.Errorf("want to be told the author was deactivated; got %+v", notifications[0])
+	}
+	if !strings.Contains(notifications[1].Message, "doesn't break the rules") || notifications[1].Link != "/snippet/1" {
+		t.Errorf("want to be told the report was dismissed; got %+v", notifications[1])
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -13,21 +15,40 @@ func (app *application) routes() http.Handler {
 	router.Use(logRequest(app))
 	router.Use(secureHeaders)
 	router.Use(middleware.GetHead)
+	router.Use(limitIPs(app))
 
 	// Public & Private Routes
 	router.Group(func(r chi.Router) {
 		r.Use(app.session.Enable)
 		r.Use(noSurf)
+		r.Use(rememberMe(app))
 		r.Use(authenticate(app))
+		r.Use(limitRequests(app))
 
 		// Public Routes
 		r.Group(func(r chi.Router) {
 			r.Get("/", app.home)
 			r.Get("/snippet/{id:[0-9]+}", app.showSnippet)
-			r.Get("/user/signup", app.signupUserForm)
-			r.Post("/user/signup", app.signupUser)
-			r.Get("/user/login", app.loginUserForm)
-			r.Post("/user/login", app.loginUser)
+			r.Get("/snippet/{id:[0-9]+}/diff", app.snippetDiff)
+			r.Get("/snippet/{id:[0-9]+}/raw/{name}", app.rawFile)
+			r.Get("/snippet/{id:[0-9]+}/zip", app.downloadSnippet)
+			r.Get("/tags/{tag}", app.tagSnippets)
+			r.Get("/c/{slug}", app.showCollection)
+			r.Get("/c/{slug}/raw", app.rawCollection)
+			r.Get("/c/{slug}/zip", app.downloadCollection)
+			r.Get("/u/{username}", app.publicProfile)
+			r.Group(func(r chi.Router) {
+				r.Use(rateLimit(app, "auth"))
+
+				r.Get("/user/signup", app.signupUserForm)
+				r.Post("/user/signup", app.signupUser)
+				r.Get("/user/login", app.loginUserForm)
+				r.Post("/user/login", app.loginUser)
+			})
+			r.Get("/user/login/oidc", app.oidcLogin)
+			r.Get("/user/login/oidc/callback", app.oidcCallback)
+
+			r.Get("/export/{name}", app.downloadExport)
 
 			r.Get("/ping", ping)
 			r.Get("/about", app.about)
@@ -40,9 +61,68 @@ func (app *application) routes() http.Handler {
 			r.Get("/user/change-password", app.changePasswordForm)
 			r.Post("/user/change-password", app.changePassword)
 			r.Get("/user/profile", app.userProfile)
+			r.Get("/user/settings", app.settingsForm)
+			r.Post("/user/settings", app.settings)
+			r.Get("/user/close-account", app.closeAccountForm)
+			r.Post("/user/close-account", app.closeAccount)
+			r.Get("/user/close-account/sso", app.closeAccountSSO)
+			r.Post("/user/export", app.requestExport)
+			r.Get("/user/notifications", app.userNotifications)
+			r.Get("/user/stars", app.userStars)
+			r.Get("/user/sessions", app.userSessions)
+			r.Post("/user/sessions/revoke", app.revokeSession)
+			r.Post("/user/sessions/revoke-all", app.revokeAllSessions)
 			r.Post("/user/logout", app.logoutUser)
 			r.Get("/snippet/create", app.createSnippetForm)
 			r.Post("/snippet/create", app.createSnippet)
+			r.With(rateLimit(app, "preview")).Post("/snippet/preview", app.previewSnippet)
+			r.Post("/snippet/{id:[0-9]+}/star", app.starSnippet)
+			r.Get("/snippet/{id:[0-9]+}/fork", app.forkSnippetForm)
+			r.Get("/snippet/{id:[0-9]+}/edit", app.editSnippetForm)
+			r.Post("/snippet/{id:[0-9]+}/edit", app.editSnippet)
+			r.Post("/snippet/{id:[0-9]+}/comments", app.createComment)
+			r.Post("/snippet/{id:[0-9]+}/collect", app.collectSnippet)
+			r.Post("/snippet/{id:[0-9]+}/report", app.reportSnippet)
+			r.Get("/user/collections", app.userCollections)
+			r.Get("/collection/create", app.createCollectionForm)
+			r.Post("/collection/create", app.createCollection)
+			r.Get("/c/{slug}/edit", app.editCollectionForm)
+			r.Post("/c/{slug}/edit", app.editCollection)
+			r.Post("/c/{slug}/delete", app.deleteCollection)
+			r.Get("/comment/{id:[0-9]+}/edit", app.editCommentForm)
+			r.Post("/comment/{id:[0-9]+}/edit", app.editComment)
+			r.Post("/comment/{id:[0-9]+}/delete", app.deleteComment)
+			r.Post("/comment/{id:[0-9]+}/hide", app.setCommentHidden(true))
+			r.Post("/comment/{id:[0-9]+}/unhide", app.setCommentHidden(false))
+		})
+
+		// Admin Routes
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(requireAuthentication(app))
+			r.Use(requireRole(app, models.RoleModerator))
+
+			r.Get("/", app.adminHome)
+
+			r.Group(func(r chi.Router) {
+				r.Use(requireRole(app, models.RoleAdmin))
+
+				r.Get("/users", app.adminUsers)
+				r.Post("/users/{id:[0-9]+}/role", app.adminSetRole)
+				r.Post("/users/{id:[0-9]+}/deactivate", app.adminSetActive(false))
+				r.Post("/users/{id:[0-9]+}/activate", app.adminSetActive(true))
+				r.Post("/users/{id:[0-9]+}/reset-password", app.adminResetPassword)
+				r.Get("/audit", app.adminAudit)
+				r.Get("/audit/export", app.adminAuditExport)
+			})
+
+			r.Get("/snippets", app.adminSnippets)
+			r.Post("/snippets/{id:[0-9]+}/hide", app.adminSetHidden(true))
+			r.Post("/snippets/{id:[0-9]+}/unhide", app.adminSetHidden(false))
+			r.Post("/snippets/{id:[0-9]+}/delete", app.adminDeleteSnippet)
+			r.Get("/reports", app.adminReports)
+			r.Post("/reports/{id:[0-9]+}/hide", app.adminResolveReport(models.ReportHidden))
+			r.Post("/reports/{id:[0-9]+}/dismiss", app.adminResolveReport(models.ReportDismissed))
+			r.Post("/reports/{id:[0-9]+}/deactivate", app.adminResolveReport(models.ReportDeactivated))
 		})
 	})
 
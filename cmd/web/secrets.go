@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/secrets"
+)
+
+// FileSecret is a secret found in a file of a snippet.
+type FileSecret struct {
+	File string
+	*secrets.Match
+}
+
+// checkSecrets scans files for secrets. Until the author says whether to
+// keep or redact them, an error is added so the form is shown again with a
+// warning. If redaction was chosen, the secrets are replaced in files.
+func checkSecrets(form *forms.Form, files []*models.File) []*FileSecret {
+	form.PermittedValues("secrets", "keep", "redact")
+
+	var found []*FileSecret
+	for _, f := range files {
+		matches := secrets.Scan(f.Content)
+		for _, m := range matches {
+			found = append(found, &FileSecret{File: f.Name, Match: m})
+		}
+		if len(matches) > 0 && form.Get("secrets") == "redact" {
+			f.Content = secrets.Redact(f.Content, matches)
+		}
+	}
+	if len(found) > 0 && form.Get("secrets") == "" {
+		form.Errors.Add("secrets", "This snippet looks like it contains secrets. Redact them, or confirm that they can be published.")
+	}
+	return found
+}
+
+// secretsSummary describes found secrets for the audit log without
+// revealing them, e.g. "main.go:3 aws-access-key-id".
+func secretsSummary(found []*FileSecret) []string {
+	var summary []string
+	for _, s := range found {
+		summary = append(summary, fmt.Sprintf("%s:%d %s", s.File, s.Line, s.Rule.Name))
+	}
+	return summary
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+const (
+	maxTags      = 5
+	maxTagLength = 30
+	cloudTags    = 30
+)
+
+// CloudTag is a tag in the tag cloud. Size goes from 1 for the least used
+// tags to 5 for the most used.
+type CloudTag struct {
+	*models.Tag
+	Size int
+}
+
+func tagCloud(tags []*models.Tag) []*CloudTag {
+	min, max := 0, 0
+	for i, t := range tags {
+		if i == 0 || t.Count < min {
+			min = t.Count
+		}
+		if t.Count > max {
+			max = t.Count
+		}
+	}
+
+	cloud := []*CloudTag{}
+	for _, t := range tags {
+		size := 1
+		if max > min {
+			size += 4 * (t.Count - min) / (max - min)
+		}
+		cloud = append(cloud, &CloudTag{t, size})
+	}
+	return cloud
+}
+
+// tagSnippets lists the live snippets with the tag in the URL.
+func (app *application) tagSnippets(w http.ResponseWriter, r *http.Request) {
+	tag, err := pathParam(r, "tag")
+	if err != nil || !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+
+	page := newPagination(r, 20)
+	snippets, err := app.tags.Snippets(tag, page.Limit(), page.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "tag.page.tmpl", &TemplateData{
+		Pagination: page,
+		Snippets:   snippets[:page.SetResults(len(snippets))],
+		Tag:        tag,
+	})
+}
+
+// tagsValue formats tags for the tags field of the snippet forms.
+func tagsValue(tags []string) string {
+	return strings.Join(tags, ", ")
+}
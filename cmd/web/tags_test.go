@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_tagCloud(t *testing.T) {
+	cloud := tagCloud([]*models.Tag{{Name: "a", Count: 1}, {Name: "b", Count: 3}, {Name: "c", Count: 5}})
+	var sizes []int
+	for _, tag := range cloud {
+		sizes = append(sizes, tag.Size)
+	}
+	if want := []int{1, 3, 5}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("want sizes %v; got %v", want, sizes)
+	}
+
+	cloud = tagCloud([]*models.Tag{{Name: "a", Count: 2}, {Name: "b", Count: 2}})
+	if cloud[0].Size != 1 || cloud[1].Size != 1 {
+		t.Errorf("want equally used tags to have size 1; got %d and %d", cloud[0].Size, cloud[1].Size)
+	}
+}
+
+func Test_tagSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Tag cloud", "/", http.StatusOK, []byte(`<a class="tag size-5" href="/tags/haiku"`)},
+		{"Snippet tags", "/snippet/1", http.StatusOK, []byte(`<a class="tag" href="/tags/poetry">poetry</a>`)},
+		{"Tagged", "/tags/haiku", http.StatusOK, []byte(`<a href="/snippet/1">An old silent pond</a>`)},
+		{"Unused tag", "/tags/go", http.StatusOK, []byte("No snippets have this tag.")},
+		{"Invalid tag", "/tags/Haiku", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_createSnippetTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		tags     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"No tags", "", http.StatusSeeOther, nil},
+		{"Normalised", "#Go, HTTP go", http.StatusSeeOther, nil},
+		{"Too many", "a b c d e f", http.StatusOK, []byte("Use at most 5 tags")},
+		{"Too long", "abcdefghijklmnopqrstuvwxyz01234", http.StatusOK, []byte("Tags can be at most 30 characters long")},
+		{"Invalid", "c/c++", http.StatusOK, []byte("Tags can only contain letters, digits")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Tagged")
+			form.Add("tags", tt.tags)
+			form.Add("file_name.0", "main.go")
+			form.Add("file_content.0", "package main")
+			form.Add("expires", "7")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
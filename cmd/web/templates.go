@@ -2,22 +2,60 @@ package main
 
 import (
 	"html/template"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/antispam"
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
 	"github.com/aesuhaendi/go-snippetbox/pkg/forms"
+	"github.com/aesuhaendi/go-snippetbox/pkg/markdown"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+	"github.com/aesuhaendi/go-snippetbox/pkg/session"
 )
 
 type TemplateData struct {
-	CSRFToken       string
-	CurrentYear     int
-	Flash           string
-	Form            *forms.Form
-	IsAuthenticated bool
-	User            *models.User
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
+	Actions           []string
+	AntiSpam          []antispam.Field
+	AuditEvents       []*audit.Event
+	AuthenticatedUser *models.User
+	CanModerate       bool
+	Collection        *models.Collection
+	Collections       []*models.Collection
+	Comment           *models.Comment
+	Comments          []*models.Comment
+	CSRFToken         string
+	CurrentYear       int
+	FileDiffs         []*FileDiff
+	Files             []*CodeFile
+	Flash             string
+	Form              *forms.Form
+	IsAuthenticated   bool
+	MostStarred       []*models.Snippet
+	Notifications     []*models.Notification
+	OIDCName          string
+	Pagination        *Pagination
+	Parent            *models.Snippet
+	Query             string
+	Reasons           []string
+	Reauthenticated   bool
+	Reports           []*models.Report
+	Roles             []string
+	Secrets           []*FileSecret
+	SessionID         string
+	Sessions          []*session.Record
+	User              *models.User
+	Users             []*models.User
+	Snippet           *models.Snippet
+	Snippets          []*models.Snippet
+	SnippetCounts     *models.SnippetCounts
+	Starred           bool
+	Tag               string
+	TagCloud          []*CloudTag
+	Unavailable       []*models.Snippet
+	UnreadCount       int
+	UserCounts        *models.UserCounts
 }
 
 func humanDate(t time.Time) string {
@@ -27,8 +65,81 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// deviceName turns a User-Agent header into a short "Browser on OS" label.
+// Only the common browsers and platforms are recognised.
+func deviceName(userAgent string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go HTTP client"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+// commentView is what the comment templates need: a comment and the page
+// it is shown on.
+type commentView struct {
+	*models.Comment
+	Page *TemplateData
+}
+
+func newCommentView(c *models.Comment, page *TemplateData) commentView {
+	return commentView{c, page}
+}
+
+// CanEdit reports whether the logged in user wrote the comment, and hasn't
+// deleted it.
+func (c commentView) CanEdit() bool {
+	return c.Page.AuthenticatedUser != nil && c.Page.AuthenticatedUser.ID == c.UserID && !c.Deleted
+}
+
+func (c commentView) CanDelete() bool {
+	return c.CanEdit() || c.Page.CanModerate
+}
+
+// ShowBody reports whether the body can be shown. Hidden comments are only
+// shown to their author and to whoever hid them.
+func (c commentView) ShowBody() bool {
+	return !c.Hidden || c.CanDelete()
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"comment":    newCommentView,
+	"deviceName": deviceName,
+	"fileAnchor": fileAnchor,
+	"humanDate":  humanDate,
+	"lineAnchor": lineAnchor,
+	"markdown":   markdown.Render,
+	"pathEscape": url.PathEscape,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
@@ -35,3 +35,39 @@ func Test_humanDate(t *testing.T) {
 		})
 	}
 }
+
+func Test_deviceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "Firefox on Linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:93.0) Gecko/20100101 Firefox/93.0",
+			want:      "Firefox on Linux",
+		},
+		{
+			name:      "Chrome on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.81 Safari/537.36",
+			want:      "Chrome on Windows",
+		},
+		{
+			name:      "Safari on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iPhone",
+		},
+		{
+			name:      "Empty",
+			userAgent: "",
+			want:      "Unknown browser",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deviceName(tt.userAgent); got != tt.want {
+				t.Errorf("deviceName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
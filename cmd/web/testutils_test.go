@@ -12,9 +12,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/antispam"
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/auth"
 	"github.com/aesuhaendi/go-snippetbox/pkg/mocks"
-
-	"github.com/golangcollege/sessions"
+	"github.com/aesuhaendi/go-snippetbox/pkg/ratelimit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/session"
+	"github.com/aesuhaendi/go-snippetbox/pkg/signedurl"
+	"github.com/aesuhaendi/go-snippetbox/pkg/throttle"
 )
 
 var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
@@ -33,17 +38,38 @@ func newTestApplication(t *testing.T) *application {
 		t.Fatal(err)
 	}
 
-	session := sessions.New([]byte("123abcdefghijklmnopqrstuvwxyz123"))
-	session.Lifetime = 12 * time.Hour
-	session.Secure = true
+	sess := session.New(session.NewMemoryStore())
+	sess.Lifetime = 12 * time.Hour
+	sess.Cookie.Secure = true
+	sess.UserIDKey = "authenticatedUserID"
+
+	attempts := throttle.NewMemoryStore()
+	users := &mocks.UserModel{}
 
 	return &application{
-		errorLog:      log.New(io.Discard, "", 0),
-		infoLog:       log.New(io.Discard, "", 0),
-		session:       session,
-		snippets:      &mocks.SnippetModel{},
-		templateCache: templateCache,
-		users:         &mocks.UserModel{},
+		accountThrottle: throttle.New(attempts, accountThrottlePolicy),
+		antispam:        antispam.New([]byte("test"), antispam.NewMemoryStore(), nil),
+		auditLog:        audit.NewMemoryStore(),
+		authenticator:   auth.Chain{users},
+		collections:     &mocks.CollectionModel{},
+		comments:        &mocks.CommentModel{},
+		errorLog:        log.New(io.Discard, "", 0),
+		exportDir:       t.TempDir(),
+		exports:         &mocks.ExportModel{},
+		identities:      &mocks.IdentityModel{},
+		infoLog:         log.New(io.Discard, "", 0),
+		ipThrottle:      throttle.New(attempts, ipThrottlePolicy),
+		notifications:   &mocks.NotificationModel{},
+		rateLimiter:     ratelimit.New(ratelimit.NewMemoryStore()),
+		rememberTokens:  &mocks.RememberTokenModel{},
+		reports:         &mocks.ReportModel{},
+		session:         sess,
+		signer:          signedurl.New([]byte("test")),
+		snippets:        &mocks.SnippetModel{},
+		stars:           &mocks.StarModel{},
+		tags:            &mocks.TagModel{},
+		templateCache:   templateCache,
+		users:           users,
 	}
 }
 
@@ -94,3 +120,14 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, body
 }
+
+func (ts *testServer) login(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+}
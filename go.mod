@@ -3,11 +3,12 @@ module github.com/aesuhaendi/go-snippetbox
 go 1.17
 
 require (
+	github.com/go-asn1-ber/asn1-ber v1.5.1
 	github.com/go-chi/chi/v5 v5.0.4
+	github.com/go-ldap/ldap/v3 v3.4.1
 	github.com/go-sql-driver/mysql v1.6.0
-	github.com/golangcollege/sessions v1.2.0
 	github.com/justinas/nosurf v1.1.1
-	golang.org/x/crypto v0.0.0-20200317142112-1b76d66859c6
+	golang.org/x/crypto v0.0.0-20200604202706-70a84ac30bf9
 )
 
-require golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+require github.com/Azure/go-ntlmssp v0.0.0-20200615164410-66371956d46c // indirect
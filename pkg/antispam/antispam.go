@@ -0,0 +1,325 @@
+// Package antispam keeps bots away from forms without an outside CAPTCHA
+// service. Each protected form carries a signed, single-use token, and a set
+// of checks decides whether a submission came from a person: a honeypot
+// field that people never see, a minimum time to fill in the form, and a
+// proof of work that the browser solves in JavaScript. What each route
+// requires is set by its Policy, and more checks can be added with Use.
+package antispam
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenField is the form field holding the token.
+const TokenField = "antispam_token"
+
+var (
+	ErrInvalidToken = errors.New("antispam: missing or invalid token")
+	ErrExpired      = errors.New("antispam: form has expired")
+	ErrReused       = errors.New("antispam: form was already submitted")
+	ErrHoneypot     = errors.New("antispam: honeypot was filled in")
+	ErrTooFast      = errors.New("antispam: form was filled in too quickly")
+	ErrNoWork       = errors.New("antispam: proof of work is missing or wrong")
+)
+
+// Policy is what a route requires. Zero fields turn their check off.
+type Policy struct {
+	Honeypot    bool
+	MinFillTime time.Duration
+	// Difficulty is the number of leading zero bits the proof of work must
+	// have. Each bit doubles the work, which averages 2^Difficulty hashes.
+	Difficulty int
+}
+
+// UnmarshalJSON reads a policy such as
+// {"honeypot": true, "min_fill_time": "3s", "difficulty": 16}.
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Honeypot    bool   `json:"honeypot"`
+		MinFillTime string `json:"min_fill_time"`
+		Difficulty  int    `json:"difficulty"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*p = Policy{Honeypot: v.Honeypot, Difficulty: v.Difficulty}
+	if v.MinFillTime != "" {
+		d, err := time.ParseDuration(v.MinFillTime)
+		if err != nil {
+			return err
+		}
+		p.MinFillTime = d
+	}
+	return nil
+}
+
+// LoadPolicies reads a JSON file mapping routes to policies.
+func LoadPolicies(path string) (map[string]Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policies := map[string]Policy{}
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("antispam: %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// Store remembers which tokens have been used. Instances sharing a store
+// refuse each other's used tokens.
+type Store interface {
+	// Use records token as used until expires. It reports false if token
+	// was already used.
+	Use(token string, expires time.Time) (bool, error)
+}
+
+// Field is a form field that a check adds to a form.
+type Field struct {
+	Name  string
+	Value string
+	// Trap fields are text inputs that are hidden from people with CSS, so
+	// only bots fill them in. Other fields are hidden inputs.
+	Trap bool
+	// Data is exposed to scripts as data-* attributes; the proof of work
+	// sets challenge and difficulty.
+	Data map[string]string
+}
+
+// Token is issued with every protected form. Its value is signed, so checks
+// can trust its time and use it as a challenge.
+type Token struct {
+	Route  string
+	Issued time.Time
+	Value  string
+}
+
+// Request is a form being issued or verified. Form is nil when issuing.
+type Request struct {
+	Policy Policy
+	Token  *Token
+	Form   url.Values
+	Now    time.Time
+}
+
+// Check is one way of telling people from bots.
+type Check interface {
+	// Fields returns the fields the check needs in the form.
+	Fields(req *Request) []Field
+	// Verify returns an error if the submission looks automated.
+	Verify(req *Request) error
+}
+
+// Guard protects the forms of the routes it has a policy for.
+type Guard struct {
+	// Policies maps routes to what they require. Routes without a policy
+	// aren't protected.
+	Policies map[string]Policy
+	// MaxAge is how long a form can be left open before it is submitted.
+	MaxAge time.Duration
+
+	key    []byte
+	store  Store
+	checks []Check
+	now    func() time.Time
+}
+
+// New returns a guard that signs tokens with key, remembers used ones in
+// store and runs the honeypot, fill time and proof-of-work checks.
+func New(key []byte, store Store, policies map[string]Policy) *Guard {
+	return &Guard{
+		Policies: policies,
+		MaxAge:   2 * time.Hour,
+		key:      key,
+		store:    store,
+		checks:   []Check{Honeypot{}, FillTime{}, ProofOfWork{}},
+		now:      time.Now,
+	}
+}
+
+// Use adds a check.
+func (g *Guard) Use(c Check) {
+	g.checks = append(g.checks, c)
+}
+
+// Fields returns the fields to add to the form that posts to route.
+func (g *Guard) Fields(route string) ([]Field, error) {
+	p, ok := g.Policies[route]
+	if !ok {
+		return nil, nil
+	}
+	token, err := g.issue(route)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{Policy: p, Token: token, Now: g.now()}
+	fields := []Field{{Name: TokenField, Value: token.Value}}
+	for _, c := range g.checks {
+		fields = append(fields, c.Fields(req)...)
+	}
+	return fields, nil
+}
+
+// Verify checks a submission of the form of route. Each token can only be
+// used once, so a solved form can't be replayed.
+func (g *Guard) Verify(route string, form url.Values) error {
+	p, ok := g.Policies[route]
+	if !ok {
+		return nil
+	}
+	token, err := g.parse(route, form.Get(TokenField))
+	if err != nil {
+		return err
+	}
+	req := &Request{Policy: p, Token: token, Form: form, Now: g.now()}
+	if req.Now.Sub(token.Issued) > g.MaxAge {
+		return ErrExpired
+	}
+	for _, c := range g.checks {
+		if err := c.Verify(req); err != nil {
+			return err
+		}
+	}
+	return g.use(token, req.Now)
+}
+
+// use marks a token as used until it would have expired anyway.
+func (g *Guard) use(token *Token, now time.Time) error {
+	ok, err := g.store.Use(token.Value, token.Issued.Add(g.MaxAge))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReused
+	}
+	return nil
+}
+
+// issue returns a token for route. Its value is the issue time in whole
+// seconds, a random salt and a signature covering both and the route.
+func (g *Guard) issue(route string) (*Token, error) {
+	salt := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	issued := g.now().Unix()
+	payload := strconv.FormatInt(issued, 10) + "." + base64.RawURLEncoding.EncodeToString(salt)
+	return &Token{
+		Route:  route,
+		Issued: time.Unix(issued, 0),
+		Value:  payload + "." + g.sign(route, payload),
+	}, nil
+}
+
+func (g *Guard) parse(route, value string) (*Token, error) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return nil, ErrInvalidToken
+	}
+	payload, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(g.sign(route, payload))) {
+		return nil, ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(strings.SplitN(payload, ".", 2)[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &Token{Route: route, Issued: time.Unix(unix, 0), Value: value}, nil
+}
+
+func (g *Guard) sign(route, payload string) string {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(route + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HoneypotField is the name of the honeypot. It looks like a field bots
+// would want to fill in.
+const HoneypotField = "website"
+
+// Honeypot rejects forms whose hidden trap field was filled in.
+type Honeypot struct{}
+
+func (Honeypot) Fields(req *Request) []Field {
+	if !req.Policy.Honeypot {
+		return nil
+	}
+	return []Field{{Name: HoneypotField, Trap: true}}
+}
+
+func (Honeypot) Verify(req *Request) error {
+	if req.Policy.Honeypot && req.Form.Get(HoneypotField) != "" {
+		return ErrHoneypot
+	}
+	return nil
+}
+
+// FillTime rejects forms submitted sooner after they were issued than a
+// person could fill them in.
+type FillTime struct{}
+
+func (FillTime) Fields(req *Request) []Field {
+	return nil
+}
+
+func (FillTime) Verify(req *Request) error {
+	if req.Now.Sub(req.Token.Issued) < req.Policy.MinFillTime {
+		return ErrTooFast
+	}
+	return nil
+}
+
+// NonceField holds the solution of the proof of work.
+const NonceField = "antispam_nonce"
+
+// ProofOfWork makes the browser find a nonce that, appended to the token,
+// has a SHA-256 hash starting with Difficulty zero bits.
+type ProofOfWork struct{}
+
+func (ProofOfWork) Fields(req *Request) []Field {
+	if req.Policy.Difficulty <= 0 {
+		return nil
+	}
+	return []Field{{
+		Name: NonceField,
+		Data: map[string]string{
+			"challenge":  req.Token.Value,
+			"difficulty": strconv.Itoa(req.Policy.Difficulty),
+		},
+	}}
+}
+
+func (ProofOfWork) Verify(req *Request) error {
+	if req.Policy.Difficulty > 0 && !Solves(req.Token.Value, req.Form.Get(NonceField), req.Policy.Difficulty) {
+		return ErrNoWork
+	}
+	return nil
+}
+
+// Solves reports whether nonce solves the proof of work for challenge.
+func Solves(challenge, nonce string, difficulty int) bool {
+	if nonce == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(challenge + nonce))
+	zeros := 0
+	for _, b := range sum {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
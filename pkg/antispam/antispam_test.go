@@ -0,0 +1,158 @@
+package antispam
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// solve finds a nonce for challenge, as the browser does.
+func solve(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		if nonce := strconv.Itoa(i); Solves(challenge, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
+
+func Test_GuardVerify(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	g := New([]byte("secret"), store, map[string]Policy{
+		"/signup": {Honeypot: true, MinFillTime: 3 * time.Second, Difficulty: 8},
+	})
+	g.now = func() time.Time { return now }
+
+	// form returns a submission of a freshly issued form, as a person would
+	// send it.
+	form := func() url.Values {
+		fields, err := g.Fields("/signup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := url.Values{}
+		for _, f := range fields {
+			data.Set(f.Name, f.Value)
+			if challenge := f.Data["challenge"]; challenge != "" {
+				data.Set(f.Name, solve(challenge, 8))
+			}
+		}
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		route   string
+		change  func(url.Values)
+		wait    time.Duration
+		wantErr error
+	}{
+		{"Valid", "/signup", nil, 5 * time.Second, nil},
+		{"Unprotected route", "/login", func(f url.Values) { f.Del(TokenField) }, 0, nil},
+		{"No token", "/signup", func(f url.Values) { f.Del(TokenField) }, 5 * time.Second, ErrInvalidToken},
+		{"Forged token", "/signup", func(f url.Values) { f.Set(TokenField, f.Get(TokenField)+"x") }, 5 * time.Second, ErrInvalidToken},
+		{"Honeypot", "/signup", func(f url.Values) { f.Set(HoneypotField, "http://spam.example.com") }, 5 * time.Second, ErrHoneypot},
+		{"Too fast", "/signup", nil, time.Second, ErrTooFast},
+		{"No work", "/signup", func(f url.Values) { f.Del(NonceField) }, 5 * time.Second, ErrNoWork},
+		{"Expired", "/signup", nil, 3 * time.Hour, ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issued := now
+			f := form()
+			if tt.change != nil {
+				tt.change(f)
+			}
+			now = now.Add(tt.wait)
+			defer func() { now = issued }()
+
+			if err := g.Verify(tt.route, f); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Replayed", func(t *testing.T) {
+		f := form()
+		now = now.Add(5 * time.Second)
+		if err := g.Verify("/signup", f); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Verify("/signup", f); !errors.Is(err, ErrReused) {
+			t.Errorf("want %v; got %v", ErrReused, err)
+		}
+	})
+}
+
+type rejectAll struct{}
+
+func (rejectAll) Fields(req *Request) []Field { return []Field{{Name: "extra", Value: "1"}} }
+
+func (rejectAll) Verify(req *Request) error { return errors.New("rejected") }
+
+func Test_GuardUse(t *testing.T) {
+	g := New([]byte("secret"), NewMemoryStore(), map[string]Policy{"/signup": {}})
+	g.Use(rejectAll{})
+
+	fields, err := g.Fields("/signup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := url.Values{}
+	for _, field := range fields {
+		f.Set(field.Name, field.Value)
+	}
+	if f.Get("extra") != "1" {
+		t.Errorf("want the check's field in the form; got %v", f)
+	}
+	if err := g.Verify("/signup", f); err == nil || err.Error() != "rejected" {
+		t.Errorf("want the check to reject the form; got %v", err)
+	}
+}
+
+func Test_Solves(t *testing.T) {
+	tests := []struct {
+		nonce      string
+		difficulty int
+		want       bool
+	}{
+		{"", 0, false},
+		{"anything", 0, true},
+		{solve("challenge", 12), 12, true},
+		{"0", 256, false},
+	}
+	for _, tt := range tests {
+		if got := Solves("challenge", tt.nonce, tt.difficulty); got != tt.want {
+			t.Errorf("Solves(%q, %d): want %v; got %v", tt.nonce, tt.difficulty, tt.want, got)
+		}
+	}
+}
+
+func Test_LoadPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "antispam.json")
+	err := os.WriteFile(path, []byte(`{"/signup": {"honeypot": true, "min_fill_time": "4s", "difficulty": 18}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := LoadPolicies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Policy{Honeypot: true, MinFillTime: 4 * time.Second, Difficulty: 18}
+	if got := policies["/signup"]; got != want {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"/signup": {"min_fill_time": "soon"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicies(path); err == nil {
+		t.Error("want an error for a bad duration")
+	}
+}
@@ -0,0 +1,35 @@
+package antispam
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore remembers used tokens in process memory. It is only suitable
+// for a single instance; use the MySQL store when running several, or a form
+// could be replayed against another instance.
+type MemoryStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+	now  func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{used: map[string]time.Time{}, now: time.Now}
+}
+
+func (m *MemoryStore) Use(token string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for value, exp := range m.used {
+		if now.After(exp) {
+			delete(m.used, value)
+		}
+	}
+	if _, ok := m.used[token]; ok {
+		return false, nil
+	}
+	m.used[token] = expires
+	return true, nil
+}
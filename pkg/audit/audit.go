@@ -0,0 +1,118 @@
+// Package audit records who did what, and when, for security reviews.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionSignup          = "user.signup"
+	ActionLogin           = "user.login"
+	ActionLoginFailed     = "user.login_failed"
+	ActionLogout          = "user.logout"
+	ActionPasswordChange  = "user.password_change"
+	ActionDeactivate      = "user.deactivate"
+	ActionDeleteRequest   = "user.delete_request"
+	ActionExport          = "user.export"
+	ActionSnippetCreate   = "snippet.create"
+	ActionSnippetUpdate   = "snippet.update"
+	ActionSnippetSecrets  = "snippet.secrets"
+	ActionSnippetReport   = "snippet.report"
+	ActionAdminSetRole    = "admin.user.set_role"
+	ActionAdminDeactivate = "admin.user.deactivate"
+	ActionAdminReactivate = "admin.user.reactivate"
+	ActionAdminResetPass  = "admin.user.reset_password"
+	ActionAdminHide       = "admin.snippet.hide"
+	ActionAdminUnhide     = "admin.snippet.unhide"
+	ActionAdminDelete     = "admin.snippet.delete"
+	ActionAdminDismiss    = "admin.report.dismiss"
+)
+
+// Actions lists every action, for filter forms.
+var Actions = []string{
+	ActionSignup,
+	ActionLogin,
+	ActionLoginFailed,
+	ActionLogout,
+	ActionPasswordChange,
+	ActionDeactivate,
+	ActionDeleteRequest,
+	ActionExport,
+	ActionSnippetCreate,
+	ActionSnippetUpdate,
+	ActionSnippetSecrets,
+	ActionSnippetReport,
+	ActionAdminSetRole,
+	ActionAdminDeactivate,
+	ActionAdminReactivate,
+	ActionAdminResetPass,
+	ActionAdminHide,
+	ActionAdminUnhide,
+	ActionAdminDelete,
+	ActionAdminDismiss,
+}
+
+// Details holds whatever else is worth knowing about an event, such as the
+// target of an admin action. It is stored as JSON.
+type Details map[string]interface{}
+
+// Event is a single entry in the audit log. ActorID is zero when nobody was
+// logged in, e.g. for a failed login.
+type Event struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	ActorID   int       `json:"actor_id,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   Details   `json:"details,omitempty"`
+}
+
+// Filter selects events. Zero fields match everything, and a zero Limit
+// means no limit.
+type Filter struct {
+	Action  string
+	ActorID int
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
+
+// Match reports whether e is selected by f, ignoring Limit and Offset.
+func (f Filter) Match(e *Event) bool {
+	switch {
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.ActorID != 0 && e.ActorID != f.ActorID:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Store persists events. It is append-only: there is deliberately no way to
+// change or remove an event once it has been recorded.
+type Store interface {
+	// Insert records e and sets its ID.
+	Insert(e *Event) error
+	// List returns the events matching f, newest first.
+	List(f Filter) ([]*Event, error)
+}
+
+// WriteJSONL writes events to w as JSON Lines, one event per line.
+func WriteJSONL(w io.Writer, events []*Event) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
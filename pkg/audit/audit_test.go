@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_MemoryStoreList(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []Event{
+		{Action: ActionLogin, ActorID: 1},
+		{Action: ActionLoginFailed},
+		{Action: ActionLogin, ActorID: 2},
+		{Action: ActionLogout, ActorID: 1},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := store.Insert(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantIDs []int64
+	}{
+		{"All", Filter{}, []int64{4, 3, 2, 1}},
+		{"Action", Filter{Action: ActionLogin}, []int64{3, 1}},
+		{"Actor", Filter{ActorID: 1}, []int64{4, 1}},
+		{"Since", Filter{Since: start.Add(2 * time.Hour)}, []int64{4, 3}},
+		{"Until", Filter{Until: start.Add(2 * time.Hour)}, []int64{2, 1}},
+		{"Limit and offset", Filter{Limit: 2, Offset: 1}, []int64{3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.List(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []int64{}
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("want %v; got %v", tt.wantIDs, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("want %v; got %v", tt.wantIDs, ids)
+				}
+			}
+		})
+	}
+}
+
+func Test_WriteJSONL(t *testing.T) {
+	events := []*Event{
+		{ID: 2, Action: ActionAdminSetRole, ActorID: 1, Details: Details{"user_id": 2, "role": "admin"}},
+		{ID: 1, Action: ActionLoginFailed, Details: Details{"email": "alice@example.com"}},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSONL(buf, events); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		e := &Event{}
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if e.ID != events[lines].ID || e.Action != events[lines].Action {
+			t.Errorf("want %+v; got %+v", events[lines], e)
+		}
+		lines++
+	}
+	if lines != len(events) {
+		t.Errorf("want %d; got %d", len(events), lines)
+	}
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"sync"
+)
+
+// MemoryStore keeps events in process memory. Events are lost on restart, so
+// it is only fit for development and tests.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Insert(e *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *MemoryStore) List(f Filter) ([]*Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := []*Event{}
+	skipped := 0
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[i]
+		if !f.Match(&e) {
+			continue
+		}
+		if skipped < f.Offset {
+			skipped++
+			continue
+		}
+		if f.Limit > 0 && len(events) == f.Limit {
+			break
+		}
+		events = append(events, &e)
+	}
+	return events, nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// Authenticator checks a login and password and returns the ID of the local
+// user they belong to. It returns models.ErrInvalidCredentials if it does not
+// recognise them.
+type Authenticator interface {
+	Authenticate(login, password string) (int, error)
+}
+
+// Chain tries each authenticator in turn and returns the first success. An
+// authenticator that fails with anything but invalid credentials, such as an
+// unreachable directory, does not stop the others from being tried.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(login, password string) (int, error) {
+	var firstErr error
+	for _, a := range c {
+		id, err := a.Authenticate(login, password)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, models.ErrInvalidCredentials) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return 0, models.ErrInvalidCredentials
+}
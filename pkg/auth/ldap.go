@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Identities provisions and syncs the local user linked to an external
+// account. *mysql.IdentityModel satisfies it.
+type Identities interface {
+	Authenticate(provider, subject, name, email string, emailVerified bool) (int, error)
+}
+
+// LDAP authenticates against a directory: it looks the user up with Filter,
+// binds as them with the given password and then provisions or syncs the
+// local user with the name and email from the directory.
+type LDAP struct {
+	URL      string
+	StartTLS bool
+	// BindDN and BindPassword are the service account used for the search.
+	// The search is anonymous if BindDN is empty.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// Filter finds the user's entry. Every %s is replaced by the escaped
+	// login.
+	Filter         string
+	NameAttribute  string
+	EmailAttribute string
+	Timeout        time.Duration
+	Identities     Identities
+	// LinkByEmail links a directory user to an existing local account with
+	// the same email on their first login. It is off by default, as it lets
+	// whoever controls the directory take over any local account, admins
+	// included.
+	LinkByEmail bool
+}
+
+func NewLDAP(rawURL, baseDN string, identities Identities) *LDAP {
+	return &LDAP{
+		URL:            rawURL,
+		BaseDN:         baseDN,
+		Filter:         "(|(uid=%s)(mail=%s))",
+		NameAttribute:  "cn",
+		EmailAttribute: "mail",
+		Timeout:        10 * time.Second,
+		Identities:     identities,
+	}
+}
+
+func (a *LDAP) Authenticate(login, password string) (int, error) {
+	// An empty password is an unauthenticated bind, which most directories
+	// accept for any DN.
+	if login == "" || password == "" {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	conn, err := ldap.DialURL(a.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.Timeout}))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetTimeout(a.Timeout)
+
+	if a.StartTLS {
+		u, err := url.Parse(a.URL)
+		if err != nil {
+			return 0, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return 0, err
+		}
+	}
+
+	if a.BindDN != "" {
+		if err := conn.Bind(a.BindDN, a.BindPassword); err != nil {
+			return 0, fmt.Errorf("ldap: service bind: %w", err)
+		}
+	}
+
+	filter := strings.ReplaceAll(a.Filter, "%s", ldap.EscapeFilter(login))
+	rs, err := conn.Search(ldap.NewSearchRequest(
+		a.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.Timeout.Seconds()), false,
+		filter, []string{a.NameAttribute, a.EmailAttribute}, nil,
+	))
+	if err != nil {
+		return 0, err
+	}
+	if len(rs.Entries) != 1 {
+		return 0, models.ErrInvalidCredentials
+	}
+	entry := rs.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	id, err := a.Identities.Authenticate("ldap:"+a.URL, entry.DN, entry.GetAttributeValue(a.NameAttribute), entry.GetAttributeValue(a.EmailAttribute), a.LinkByEmail)
+	if errors.Is(err, models.ErrDuplicateEmail) {
+		// A local account already has the email and isn't linked; its owner
+		// logs in with its own password.
+		return 0, models.ErrInvalidCredentials
+	}
+	return id, err
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+type directoryEntry struct {
+	dn, uid, mail, cn, password string
+}
+
+// ldapStub is just enough of an LDAP server to answer the simple binds and
+// equality searches LDAP.Authenticate makes.
+type ldapStub struct {
+	net.Listener
+	entries []directoryEntry
+}
+
+func newLDAPStub(t *testing.T, entries ...directoryEntry) *ldapStub {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ldapStub{Listener: l, entries: entries}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStub) URL() string {
+	return "ldap://" + s.Addr().String()
+}
+
+func (s *ldapStub) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+		switch op.Tag {
+		case 0: // BindRequest
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := int64(49) // invalidCredentials
+			for _, e := range s.entries {
+				if e.dn == dn && e.password == password {
+					code = 0
+				}
+			}
+			conn.Write(response(id, 1, code).Bytes())
+		case 3: // SearchRequest
+			values := map[string]bool{}
+			collectValues(op.Children[6], values)
+			for _, e := range s.entries {
+				if values[e.uid] || values[e.mail] {
+					conn.Write(searchEntry(id, e).Bytes())
+				}
+			}
+			conn.Write(response(id, 5, 0).Bytes())
+		default: // UnbindRequest and anything else ends the connection
+			return
+		}
+	}
+}
+
+func collectValues(p *ber.Packet, values map[string]bool) {
+	if len(p.Children) == 0 {
+		values[p.Data.String()] = true
+	}
+	for _, c := range p.Children {
+		collectValues(c, values)
+	}
+}
+
+func envelope(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+	return p
+}
+
+func response(id int64, tag ber.Tag, code int64) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return envelope(id, op)
+}
+
+func searchEntry(id int64, e directoryEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, value := range map[string]string{"cn": e.cn, "mail": e.mail} {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return envelope(id, op)
+}
+
+type identitiesStub struct {
+	provider, subject, name, email string
+	emailVerified                  bool
+}
+
+func (s *identitiesStub) Authenticate(provider, subject, name, email string, emailVerified bool) (int, error) {
+	s.provider, s.subject, s.name, s.email, s.emailVerified = provider, subject, name, email, emailVerified
+	return 42, nil
+}
+
+func Test_LDAPAuthenticate(t *testing.T) {
+	stub := newLDAPStub(t, directoryEntry{
+		dn:       "uid=bob,ou=people,dc=example,dc=com",
+		uid:      "bob",
+		mail:     "bob@example.com",
+		cn:       "Bob Smith",
+		password: "directorypassword",
+	})
+	defer stub.Close()
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{"By uid", "bob", "directorypassword", 42, nil},
+		{"By email", "bob@example.com", "directorypassword", 42, nil},
+		{"Wrong password", "bob", "wrong", 0, models.ErrInvalidCredentials},
+		{"Empty password", "bob", "", 0, models.ErrInvalidCredentials},
+		{"Unknown user", "carol", "directorypassword", 0, models.ErrInvalidCredentials},
+		{"Filter injection", "*", "directorypassword", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identities := &identitiesStub{}
+			a := NewLDAP(stub.URL(), "dc=example,dc=com", identities)
+
+			id, err := a.Authenticate(tt.login, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v; got %v", tt.wantErr, err)
+			}
+			if id != tt.wantID {
+				t.Errorf("want %d; got %d", tt.wantID, id)
+			}
+			if tt.wantErr == nil {
+				if identities.subject != "uid=bob,ou=people,dc=example,dc=com" || identities.name != "Bob Smith" || identities.email != "bob@example.com" {
+					t.Errorf("want the user to be provisioned from the directory; got %+v", identities)
+				}
+				if identities.emailVerified {
+					t.Error("want no linking to local accounts by email by default")
+				}
+			}
+		})
+	}
+}
+
+type authenticatorFunc func(string, string) (int, error)
+
+func (f authenticatorFunc) Authenticate(login, password string) (int, error) {
+	return f(login, password)
+}
+
+func Test_Chain(t *testing.T) {
+	invalid := authenticatorFunc(func(string, string) (int, error) { return 0, models.ErrInvalidCredentials })
+	broken := authenticatorFunc(func(string, string) (int, error) { return 0, errors.New("directory down") })
+	valid := authenticatorFunc(func(string, string) (int, error) { return 7, nil })
+
+	tests := []struct {
+		name    string
+		chain   Chain
+		wantID  int
+		wantErr string
+	}{
+		{"First succeeds", Chain{valid, invalid}, 7, ""},
+		{"Falls through", Chain{invalid, valid}, 7, ""},
+		{"Broken backend is skipped", Chain{broken, valid}, 7, ""},
+		{"All invalid", Chain{invalid, invalid}, 0, models.ErrInvalidCredentials.Error()},
+		{"Broken backend reported", Chain{invalid, broken}, 0, "directory down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := tt.chain.Authenticate("alice", "password")
+			if id != tt.wantID {
+				t.Errorf("want %d; got %d", tt.wantID, id)
+			}
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("want error %q; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package diff compares two texts line by line.
+package diff
+
+// Op says what happened to a line. It doubles as a CSS class name.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Delete Op = "delete"
+	Insert Op = "insert"
+)
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the size of the table used to find the longest common
+// subsequence. Texts that differ in more lines than that are shown as
+// deleted and inserted in full.
+const maxCells = 4 << 20
+
+// Lines returns the changes that turn a into b, based on the longest common
+// subsequence of their lines. Deletions come before insertions.
+func Lines(a, b []string) []Line {
+	var prefix, suffix []Line
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, Line{Equal, a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]Line{{Equal, a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	lines := prefix
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		lines = appendAll(lines, Delete, a)
+		lines = appendAll(lines, Insert, b)
+		return append(lines, suffix...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, b[j]})
+			j++
+		}
+	}
+	lines = appendAll(lines, Delete, a[i:])
+	lines = appendAll(lines, Insert, b[j:])
+	return append(lines, suffix...)
+}
+
+func appendAll(lines []Line, op Op, texts []string) []Line {
+	for _, t := range texts {
+		lines = append(lines, Line{op, t})
+	}
+	return lines
+}
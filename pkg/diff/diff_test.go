@@ -0,0 +1,44 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Lines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"Same", "a\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"Insert", "a\nc", "a\nb\nc", []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"Delete", "a\nb\nc", "a\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}}},
+		{"Replace", "a\nb\nc", "a\nx\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}}},
+		{"Move", "a\nb\nc", "b\nc\na", []Line{{Delete, "a"}, {Equal, "b"}, {Equal, "c"}, {Insert, "a"}}},
+		{"Empty", "", "a", []Line{{Delete, ""}, {Insert, "a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(strings.Split(tt.a, "\n"), strings.Split(tt.b, "\n"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_LinesTooLarge(t *testing.T) {
+	a := make([]string, 3000)
+	b := make([]string, 3000)
+	for i := range a {
+		a[i] = "a" + strings.Repeat("x", i)
+		b[i] = "b" + strings.Repeat("x", i)
+	}
+	got := Lines(a, b)
+	if len(got) != 6000 || got[0].Op != Delete || got[5999].Op != Insert {
+		t.Errorf("want every line deleted then inserted; got %d lines", len(got))
+	}
+}
@@ -0,0 +1,179 @@
+// Package envelope encrypts data with envelope encryption. Each record is
+// sealed with its own random data key using AES-256-GCM, and the data key is
+// stored wrapped by a master key. Rotating a master key only re-wraps the
+// data keys; the records themselves don't change.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of master and data keys in bytes.
+const KeySize = 32
+
+var (
+	ErrUnknownKey = errors.New("envelope: unknown master key")
+	ErrDecrypt    = errors.New("envelope: message authentication failed")
+)
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master keys. The first one wraps new data keys; the
+// others are only used to unwrap, so that they can be rotated out.
+type Keyring struct {
+	keys []*masterKey
+}
+
+// NewKeyring returns a keyring of KeySize byte master keys, the current one
+// first.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("envelope: no master keys")
+	}
+	k := &Keyring{}
+	for _, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("envelope: master keys must be %d bytes, got %d", KeySize, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		k.keys = append(k.keys, &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead})
+	}
+	return k, nil
+}
+
+// Parse reads base64 master keys separated by commas or newlines, the
+// current one first. Lines starting with # are comments.
+func Parse(s string) (*Keyring, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, field := range strings.Split(line, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("envelope: master key %d isn't valid base64", len(keys)+1)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return NewKeyring(keys...)
+}
+
+// Load reads the master keys from the file at path or, if path is empty,
+// from the environment variable env. It returns nil if neither is set.
+func Load(path, env string) (*Keyring, error) {
+	s := os.Getenv(env)
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	return Parse(s)
+}
+
+// CurrentID returns the ID of the master key that wraps new data keys.
+func (k *Keyring) CurrentID() string {
+	return k.keys[0].id
+}
+
+// NewDataKey returns a random data key, wrapped by the current master key,
+// and the ID of that key.
+func (k *Keyring) NewDataKey() (key, wrapped []byte, keyID string, err error) {
+	key = make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, "", err
+	}
+	wrapped, keyID, err = k.Wrap(key)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return key, wrapped, keyID, nil
+}
+
+// Wrap encrypts a data key with the current master key.
+func (k *Keyring) Wrap(key []byte) (wrapped []byte, keyID string, err error) {
+	wrapped, err = seal(k.keys[0].aead, key)
+	return wrapped, k.keys[0].id, err
+}
+
+// Unwrap decrypts a data key wrapped by the master key keyID.
+func (k *Keyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	for _, mk := range k.keys {
+		if mk.id == keyID {
+			return open(mk.aead, wrapped)
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+}
+
+// Encrypt seals plaintext with a data key.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return seal(aead, plaintext)
+}
+
+// Decrypt opens a ciphertext sealed by Encrypt.
+func Decrypt(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns a random nonce followed by the sealed plaintext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
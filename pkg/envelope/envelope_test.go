@@ -0,0 +1,130 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, KeySize)
+	newKey = bytes.Repeat([]byte{2}, KeySize)
+)
+
+func Test_KeyringRotation(t *testing.T) {
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, wrapped, keyID, err := old.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(key, []byte("package main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.CurrentID() == keyID {
+		t.Fatal("want a new current key")
+	}
+	unwrapped, err := rotated.Unwrap(keyID, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, newID, err := rotated.Wrap(unwrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID != rotated.CurrentID() {
+		t.Errorf("want %q; got %q", rotated.CurrentID(), newID)
+	}
+
+	latest, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := latest.Unwrap(keyID, wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("want %v; got %v", ErrUnknownKey, err)
+	}
+	key, err = latest.Unwrap(newID, rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "package main" {
+		t.Errorf("want %q; got %q", "package main", plaintext)
+	}
+}
+
+func Test_Decrypt(t *testing.T) {
+	ciphertext, err := Encrypt(oldKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+		wantErr    error
+	}{
+		{"Valid", oldKey, ciphertext, nil},
+		{"Wrong key", newKey, ciphertext, ErrDecrypt},
+		{"Tampered", oldKey, tampered, ErrDecrypt},
+		{"Too short", oldKey, ciphertext[:4], ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.key, tt.ciphertext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_Load(t *testing.T) {
+	b64 := func(key []byte) string { return base64.StdEncoding.EncodeToString(key) }
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# current\n"+b64(newKey)+"\n"+b64(oldKey)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_MASTER_KEYS", b64(oldKey))
+
+	want, _ := NewKeyring(newKey)
+	k, err := Load(path, "TEST_MASTER_KEYS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k.keys) != 2 || k.CurrentID() != want.CurrentID() {
+		t.Errorf("want 2 keys, the file's first current; got %d", len(k.keys))
+	}
+
+	k, err = Load("", "TEST_MASTER_KEYS")
+	if err != nil || len(k.keys) != 1 {
+		t.Errorf("want the key from the environment; got %v", err)
+	}
+
+	k, err = Load("", "TEST_NO_MASTER_KEYS")
+	if err != nil || k != nil {
+		t.Errorf("want no keyring; got %v, %v", k, err)
+	}
+
+	if _, err := Parse("c2hvcnQ="); err == nil {
+		t.Error("want an error for a short key")
+	}
+}
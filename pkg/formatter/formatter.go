@@ -0,0 +1,54 @@
+// Package formatter formats source code. Formatters are registered by the
+// language names of package highlight; Go is formatted in-process with
+// go/format.
+package formatter
+
+import (
+	"go/format"
+	"sync"
+)
+
+// Formatter formats the source of one language. It returns an error, such
+// as a parse error, instead of formatting code it doesn't understand.
+type Formatter interface {
+	Format(src string) (string, error)
+}
+
+// Func lets an ordinary function be used as a Formatter.
+type Func func(src string) (string, error)
+
+func (f Func) Format(src string) (string, error) {
+	return f(src)
+}
+
+var (
+	mu         sync.RWMutex
+	formatters = map[string]Formatter{}
+)
+
+func init() {
+	Register("go", Func(formatGo))
+}
+
+// Register makes f the formatter of lang, replacing any formatter it had.
+func Register(lang string, f Formatter) {
+	mu.Lock()
+	defer mu.Unlock()
+	formatters[lang] = f
+}
+
+// For returns the formatter of lang, or nil if it has none.
+func For(lang string) Formatter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return formatters[lang]
+}
+
+// formatGo formats a Go file, or a list of declarations or statements.
+func formatGo(src string) (string, error) {
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,54 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Go(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{"File", "package main\nfunc main(){\nx:=1\n_=x}\n", "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n", ""},
+		{"Statements", "x:=1", "x := 1", ""},
+		{"Parse error", "package main\nfunc main() {", "", "expected '}'"},
+	}
+
+	f := For("go")
+	if f == nil {
+		t.Fatal("want a Go formatter")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Format(tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("want error containing %q; got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Register(t *testing.T) {
+	if For("shout") != nil {
+		t.Fatal("want no formatter before registering one")
+	}
+	Register("shout", Func(func(src string) (string, error) {
+		return strings.ToUpper(src), nil
+	}))
+	got, err := For("shout").Format("hi")
+	if err != nil || got != "HI" {
+		t.Errorf("want %q; got %q, %v", "HI", got, err)
+	}
+}
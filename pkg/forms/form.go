@@ -4,12 +4,27 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// UsernameRX matches usernames: 3 to 30 lowercase letters, digits, hyphens
+// and underscores, starting with a letter.
+var UsernameRX = regexp.MustCompile("^[a-z][a-z0-9_-]{2,29}$")
+
+// SlugRX matches URL slugs: lowercase letters and digits in words joined by
+// single hyphens.
+var SlugRX = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
+// TagRX matches normalised tags: lowercase letters, digits, +, #, . and -,
+// starting with a letter or digit.
+var TagRX = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}+#.-]*$`)
+
 type Form struct {
 	url.Values
 	Errors errors
@@ -75,6 +90,107 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// IntBetween checks that field is a whole number from min to max.
+func (f *Form) IntBetween(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be a whole number from %d to %d", min, max))
+	}
+}
+
+// ValidUsername checks that field is a username that can be used in a
+// profile URL.
+func (f *Form) ValidUsername(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if !UsernameRX.MatchString(value) {
+		f.Errors.Add(field, "Use 3 to 30 lowercase letters, digits, - or _, starting with a letter")
+	}
+}
+
+// ValidSlug checks that field can be used as the last part of a URL.
+func (f *Form) ValidSlug(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if !SlugRX.MatchString(value) {
+		f.Errors.Add(field, "Use lowercase letters and digits, separated by single hyphens")
+	}
+}
+
+// ValidFileName checks that field can be used as the name of a file in a
+// snippet, its raw URL and its ZIP download.
+func (f *Form) ValidFileName(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if value == "." || value == ".." || strings.ContainsAny(value, "/\\") || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		f.Errors.Add(field, "Use a file name without slashes")
+	}
+}
+
+// Tags returns the tags in field, normalised: they are separated by commas
+// or spaces, lowercased, stripped of a leading # and deduplicated.
+func (f *Form) Tags(field string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.FieldsFunc(f.Get(field), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ValidTags checks that field has at most max tags, each at most maxLength
+// characters long once normalised.
+func (f *Form) ValidTags(field string, max, maxLength int) {
+	tags := f.Tags(field)
+	if len(tags) > max {
+		f.Errors.Add(field, fmt.Sprintf("Use at most %d tags", max))
+		return
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxLength {
+			f.Errors.Add(field, fmt.Sprintf("Tags can be at most %d characters long", maxLength))
+			return
+		}
+		if !TagRX.MatchString(tag) {
+			f.Errors.Add(field, "Tags can only contain letters, digits, +, #, . and -")
+			return
+		}
+	}
+}
+
+// Indexes returns, in order, the indexes i of the fields named prefix.i.
+// Forms that let users add and remove rows name their fields that way.
+func (f *Form) Indexes(prefix string) []int {
+	indexes := []int{}
+	for key := range f.Values {
+		if !strings.HasPrefix(key, prefix+".") {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(key, prefix+"."))
+		if err == nil && i >= 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
 }
@@ -0,0 +1,277 @@
+// Package highlight does simple syntax highlighting: it marks up the
+// comments, strings, numbers and keywords of a few common languages. It
+// doesn't parse anything, so it is fast and never fails, but it can be fooled.
+package highlight
+
+import (
+	"html"
+	"html/template"
+	"path"
+	"strings"
+)
+
+// Plain is the language of files that aren't highlighted.
+const Plain = "text"
+
+type language struct {
+	keywords     []string
+	lineComments []string
+	blockComment [2]string
+	// quotes start strings that end at the same quote or the end of the line.
+	quotes string
+	// longStrings start strings that end at the same delimiter and can span
+	// lines, such as Go's raw strings. They have no escapes.
+	longStrings []string
+}
+
+var languages = map[string]*language{
+	"go": {
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+			"range", "return", "select", "struct", "switch", "type", "var", "nil", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		longStrings:  []string{"`"},
+	},
+	"javascript": {
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue",
+			"default", "delete", "do", "else", "export", "extends", "finally", "for", "function", "if",
+			"import", "in", "instanceof", "let", "new", "null", "of", "return", "super", "switch",
+			"this", "throw", "try", "typeof", "undefined", "var", "void", "while", "yield", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		longStrings:  []string{"`"},
+	},
+	"python": {
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def",
+			"del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+			"is", "lambda", "None", "nonlocal", "not", "or", "pass", "raise", "return", "True", "False",
+			"try", "while", "with", "yield"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		longStrings:  []string{`"""`, `'''`},
+	},
+	"shell": {
+		keywords: []string{"case", "do", "done", "elif", "else", "esac", "export", "fi", "for",
+			"function", "if", "in", "local", "return", "then", "until", "while"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"sql": {
+		keywords: []string{"add", "alter", "and", "as", "asc", "by", "create", "default", "delete",
+			"desc", "drop", "exists", "from", "group", "having", "index", "insert", "into", "is", "join",
+			"key", "left", "limit", "not", "null", "on", "or", "order", "primary", "select", "set",
+			"table", "union", "unique", "update", "values", "where"},
+		lineComments: []string{"--", "#"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+}
+
+// caseInsensitive languages match keywords regardless of case.
+var caseInsensitive = map[string]bool{"sql": true}
+
+var extensions = map[string]string{
+	".go":   "go",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".ts":   "javascript",
+	".json": "javascript",
+	".py":   "python",
+	".sh":   "shell",
+	".bash": "shell",
+	".sql":  "sql",
+	".md":   "markdown",
+}
+
+// Language returns the language of a file from its name. Files in a language
+// that isn't known are Plain.
+func Language(filename string) string {
+	if lang, ok := extensions[strings.ToLower(path.Ext(filename))]; ok {
+		return lang
+	}
+	if filename == "Dockerfile" || filename == "Makefile" {
+		return "shell"
+	}
+	return Plain
+}
+
+// aliases are the other names languages go by in Markdown code fences.
+var aliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"json":       "javascript",
+	"ts":         "javascript",
+	"typescript": "javascript",
+	"py":         "python",
+	"bash":       "shell",
+	"sh":         "shell",
+	"zsh":        "shell",
+	"mysql":      "sql",
+}
+
+// ByName returns the language called name, such as the language of a
+// Markdown code fence. Languages that aren't known are Plain.
+func ByName(name string) string {
+	name = strings.ToLower(name)
+	if _, ok := languages[name]; ok {
+		return name
+	}
+	if lang, ok := aliases[name]; ok {
+		return lang
+	}
+	return Plain
+}
+
+type token struct {
+	class string
+	text  string
+}
+
+// Lines highlights src and returns its lines as HTML. src is split on
+// newlines after dropping a single trailing newline.
+func Lines(lang, src string) []template.HTML {
+	src = strings.TrimSuffix(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var tokens []token
+	if l, ok := languages[lang]; ok {
+		tokens = l.tokenize(src, caseInsensitive[lang])
+	} else {
+		tokens = []token{{"", src}}
+	}
+
+	lines := []template.HTML{}
+	var b strings.Builder
+	for _, t := range tokens {
+		for i, part := range strings.Split(t.text, "\n") {
+			if i > 0 {
+				lines = append(lines, template.HTML(b.String()))
+				b.Reset()
+			}
+			if part == "" {
+				continue
+			}
+			if t.class == "" {
+				b.WriteString(html.EscapeString(part))
+			} else {
+				b.WriteString(`<span class="hl-` + t.class + `">` + html.EscapeString(part) + "</span>")
+			}
+		}
+	}
+	return append(lines, template.HTML(b.String()))
+}
+
+func (l *language) tokenize(src string, foldCase bool) []token {
+	keywords := map[string]bool{}
+	for _, k := range l.keywords {
+		keywords[k] = true
+	}
+
+	var tokens []token
+	plain := 0
+	emit := func(start, end int, class string) {
+		if plain < start {
+			tokens = append(tokens, token{"", src[plain:start]})
+		}
+		tokens = append(tokens, token{class, src[start:end]})
+		plain = end
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		if end := l.comment(rest); end > 0 {
+			emit(i, i+end, "com")
+			i += end
+			continue
+		}
+		if end := l.str(rest); end > 0 {
+			emit(i, i+end, "str")
+			i += end
+			continue
+		}
+
+		c := src[i]
+		switch {
+		case isDigit(c) && (i == 0 || !isIdent(src[i-1])):
+			end := i + 1
+			for end < len(src) && (isIdent(src[end]) || src[end] == '.') {
+				end++
+			}
+			emit(i, end, "num")
+			i = end
+		case isIdent(c):
+			end := i + 1
+			for end < len(src) && isIdent(src[end]) {
+				end++
+			}
+			word := src[i:end]
+			if foldCase {
+				word = strings.ToLower(word)
+			}
+			if keywords[word] {
+				emit(i, end, "kw")
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	if plain < len(src) {
+		tokens = append(tokens, token{"", src[plain:]})
+	}
+	return tokens
+}
+
+// comment returns the length of the comment at the start of s, or zero.
+func (l *language) comment(s string) int {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	if open := l.blockComment[0]; open != "" && strings.HasPrefix(s, open) {
+		if end := strings.Index(s[len(open):], l.blockComment[1]); end >= 0 {
+			return len(open) + end + len(l.blockComment[1])
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// str returns the length of the string literal at the start of s, or zero.
+func (l *language) str(s string) int {
+	for _, delim := range l.longStrings {
+		if strings.HasPrefix(s, delim) {
+			if end := strings.Index(s[len(delim):], delim); end >= 0 {
+				return len(delim) + end + len(delim)
+			}
+			return len(s)
+		}
+	}
+	if s == "" || strings.IndexByte(l.quotes, s[0]) < 0 {
+		return 0
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[0]:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,104 @@
+package highlight
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+)
+
+func Test_Language(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"main.go", "go"},
+		{"schema.SQL", "sql"},
+		{"README.md", "markdown"},
+		{"go.mod", Plain},
+		{"notes", Plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := Language(tt.filename); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_ByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"go", "go"},
+		{"JS", "javascript"},
+		{"bash", "shell"},
+		{"cobol", Plain},
+		{"", Plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ByName(tt.name); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Lines(t *testing.T) {
+	tests := []struct {
+		name string
+		lang string
+		src  string
+		want []template.HTML
+	}{
+		{
+			name: "Plain",
+			lang: Plain,
+			src:  "<b>\nif\n",
+			want: []template.HTML{"&lt;b&gt;", "if"},
+		},
+		{
+			name: "Go",
+			lang: "go",
+			src:  `return "a\"b", 42 // done`,
+			want: []template.HTML{`<span class="hl-kw">return</span> <span class="hl-str">&#34;a\&#34;b&#34;</span>, <span class="hl-num">42</span> <span class="hl-com">// done</span>`},
+		},
+		{
+			name: "Spans lines",
+			lang: "go",
+			src:  "/* a\nb */ x",
+			want: []template.HTML{`<span class="hl-com">/* a</span>`, `<span class="hl-com">b */</span> x`},
+		},
+		{
+			name: "Identifier with digits",
+			lang: "python",
+			src:  "x1 = 'y' # z",
+			want: []template.HTML{`x1 = <span class="hl-str">&#39;y&#39;</span> <span class="hl-com"># z</span>`},
+		},
+		{
+			name: "Case insensitive keywords",
+			lang: "sql",
+			src:  "SELECT 1",
+			want: []template.HTML{`<span class="hl-kw">SELECT</span> <span class="hl-num">1</span>`},
+		},
+		{
+			name: "Empty lines",
+			lang: "go",
+			src:  "a\n\nb",
+			want: []template.HTML{"a", "", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.lang, tt.src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package markdown renders Markdown. Render handles the small, safe subset
+// allowed in comments: paragraphs, bullet lists, fenced code blocks, inline
+// code, emphasis and links. Everything else is shown as text, so the output
+// never contains HTML from the input. RenderDocument handles the larger
+// subset used in Markdown files, which may contain HTML, and cleans its
+// output with package sanitize.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/highlight"
+	"github.com/aesuhaendi/go-snippetbox/pkg/sanitize"
+)
+
+var (
+	linkRX   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRX = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emRX     = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+
+	headingRX   = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?$`)
+	ruleRX      = regexp.MustCompile(`^(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	orderedRX   = regexp.MustCompile(`^(\d{1,9})[.)]\s+(.*)$`)
+	htmlBlockRX = regexp.MustCompile(`^(?:</?[A-Za-z]|<!--)`)
+)
+
+// maxQuoteDepth is how deeply block quotes nest. Markers past it are shown
+// as text.
+const maxQuoteDepth = 8
+
+// safeSchemes are the link schemes that are rendered as links. Any other
+// link is shown as text.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Render converts src to HTML.
+func Render(src string) template.HTML {
+	return template.HTML(render(src, false, 0))
+}
+
+// RenderDocument converts src to HTML. On top of what Render supports, it
+// renders headings, numbered lists, block quotes and rules, highlights
+// fenced code, and passes blocks of HTML through to be sanitised.
+func RenderDocument(src string) template.HTML {
+	return sanitize.HTML(render(src, true, 0))
+}
+
+// render converts src to HTML, with the extra blocks of documents if doc is
+// set. depth is how many block quotes src is nested in.
+func render(src string, doc bool, depth int) string {
+	var b strings.Builder
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var para, list []string
+	listTag, listStart := "ul", 1
+
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>")
+			for i, l := range para {
+				if i > 0 {
+					b.WriteString("<br>\n")
+				}
+				b.WriteString(inline(l))
+			}
+			b.WriteString("</p>\n")
+			para = nil
+		}
+		if len(list) > 0 {
+			if listTag == "ol" && listStart != 1 {
+				fmt.Fprintf(&b, "<ol start=\"%d\">\n", listStart)
+			} else {
+				b.WriteString("<" + listTag + ">\n")
+			}
+			for _, l := range list {
+				b.WriteString("<li>" + inline(l) + "</li>\n")
+			}
+			b.WriteString("</" + listTag + ">\n")
+			list = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			writeCodeBlock(&b, lang, strings.Join(code, "\n"), doc)
+		case trimmed == "":
+			flush()
+		case doc && ruleRX.MatchString(trimmed):
+			flush()
+			b.WriteString("<hr>\n")
+		case doc && headingRX.MatchString(trimmed):
+			flush()
+			m := headingRX.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+		case doc && depth < maxQuoteDepth && strings.HasPrefix(trimmed, ">"):
+			// All the levels the quote opens with are stripped at once, so
+			// that a long run of markers isn't rendered once per level.
+			flush()
+			levels, _ := unquote(trimmed, maxQuoteDepth-depth)
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				_, q := unquote(strings.TrimSpace(lines[i]), levels)
+				quote = append(quote, q)
+			}
+			i--
+			b.WriteString(strings.Repeat("<blockquote>\n", levels))
+			b.WriteString(render(strings.Join(quote, "\n"), true, depth+levels))
+			b.WriteString(strings.Repeat("</blockquote>\n", levels))
+		case doc && htmlBlockRX.MatchString(trimmed):
+			// HTML blocks run to the next blank line.
+			flush()
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.WriteString(lines[i] + "\n")
+			}
+			i--
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			if len(para) > 0 || listTag != "ul" {
+				flush()
+			}
+			listTag = "ul"
+			list = append(list, trimmed[2:])
+		case doc && orderedRX.MatchString(trimmed):
+			if len(para) > 0 || listTag != "ol" {
+				flush()
+			}
+			m := orderedRX.FindStringSubmatch(trimmed)
+			if len(list) == 0 {
+				listStart, _ = strconv.Atoi(m[1])
+			}
+			listTag = "ol"
+			list = append(list, m[2])
+		default:
+			if len(list) > 0 {
+				flush()
+			}
+			para = append(para, line)
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// unquote strips up to max quote markers from the start of line, and returns
+// how many it stripped and what is left.
+func unquote(line string, max int) (int, string) {
+	n := 0
+	for ; n < max && strings.HasPrefix(line, ">"); n++ {
+		line = strings.TrimLeft(line[1:], " \t")
+	}
+	return n, line
+}
+
+// writeCodeBlock writes a fenced code block, highlighted if highlighted is
+// set.
+func writeCodeBlock(b *strings.Builder, lang, code string, highlighted bool) {
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	b.WriteString(">")
+	if highlighted {
+		for i, line := range highlight.Lines(highlight.ByName(lang), code) {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(string(line))
+		}
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// inline renders the spans of a single line. Code spans are taken out
+// first so nothing inside them is formatted.
+func inline(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "`")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start+1:], "`")
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		b.WriteString(links(s[:start]))
+		b.WriteString("<code>" + html.EscapeString(s[start+1:end]) + "</code>")
+		s = s[end+1:]
+	}
+	b.WriteString(links(s))
+	return b.String()
+}
+
+// links renders the links in s, and the emphasis around and inside them.
+func links(s string) string {
+	var b strings.Builder
+	for {
+		m := linkRX.FindStringSubmatchIndex(s)
+		if m == nil {
+			break
+		}
+		text, href := s[m[2]:m[3]], s[m[4]:m[5]]
+		b.WriteString(emphasis(s[:m[0]]))
+		if u, err := url.Parse(href); err == nil && safeSchemes[strings.ToLower(u.Scheme)] {
+			b.WriteString(`<a href="` + html.EscapeString(u.String()) + `" rel="nofollow noopener">` + emphasis(text) + "</a>")
+		} else {
+			b.WriteString(emphasis(s[m[0]:m[1]]))
+		}
+		s = s[m[1]:]
+	}
+	b.WriteString(emphasis(s))
+	return b.String()
+}
+
+func emphasis(s string) string {
+	s = html.EscapeString(s)
+	s = strongRX.ReplaceAllString(s, "<strong>$1</strong>")
+	return emRX.ReplaceAllString(s, "<em>$1</em>")
+}
@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Render(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Paragraphs", "one\ntwo\n\nthree", "<p>one<br>\ntwo</p>\n<p>three</p>\n"},
+		{"Emphasis", "**bold** and *em*", "<p><strong>bold</strong> and <em>em</em></p>\n"},
+		{"Code span", "use `a *b* <c>`", "<p>use <code>a *b* &lt;c&gt;</code></p>\n"},
+		{"Link", "[docs](https://go.dev/?a=1&b=2)", `<p><a href="https://go.dev/?a=1&amp;b=2" rel="nofollow noopener">docs</a></p>` + "\n"},
+		{"Unsafe link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>\n"},
+		{"HTML", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>\n"},
+		{"List", "- one\n- *two*", "<ul>\n<li>one</li>\n<li><em>two</em></li>\n</ul>\n"},
+		{"Fenced code", "```go\nfmt.Println(\"<hi>\")\n```", `<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>` + "\n"},
+		{"Unclosed fence", "```\ncode", "<pre><code>code</code></pre>\n"},
+		{"Attribute injection", `[x](https://a"onmouseover="b)`, `<p><a href="https://a&#34;onmouseover=&#34;b" rel="nofollow noopener">x</a></p>` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Render(tt.src))
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_RenderDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Heading", "# Title #\n## *Sub*", "<h1>Title</h1>\n<h2><em>Sub</em></h2>\n"},
+		{"Numbered list", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"Rule", "a\n\n* * *", "<p>a</p>\n<hr>\n"},
+		{"Quote", "> # Hi\n> there\nafter", "<blockquote>\n<h1>Hi</h1>\n<p>there</p>\n</blockquote>\n<p>after</p>\n"},
+		{"Nested quote", "> > a\n> b", "<blockquote>\n<blockquote>\n<p>a<br>\nb</p>\n</blockquote>\n</blockquote>\n"},
+		{"Highlighted code", "```go\nreturn 1\n```", `<pre><code class="language-go"><span class="hl-kw">return</span> <span class="hl-num">1</span></code></pre>` + "\n"},
+		{"HTML block", "<details><summary>More</summary>\nHidden</details>", "<details><summary>More</summary>\nHidden</details>\n"},
+		{"Unsafe HTML", "<div onclick=\"x()\">a<script>alert(1)</script></div>\n\nb", "a\n<p>b</p>\n"},
+		{"Inline HTML", "a <b>b</b>", "<p>a &lt;b&gt;b&lt;/b&gt;</p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(RenderDocument(tt.src))
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_RenderDocumentDeepQuote(t *testing.T) {
+	got := string(RenderDocument(strings.Repeat(">", 40000) + " deep"))
+	if n := strings.Count(got, "<blockquote>"); n != maxQuoteDepth {
+		t.Errorf("want %d block quotes; got %d", maxQuoteDepth, n)
+	}
+	if !strings.Contains(got, "&gt; deep") {
+		t.Errorf("want the markers past the limit shown as text")
+	}
+}
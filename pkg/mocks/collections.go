@@ -0,0 +1,107 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+var mockCollection = &models.Collection{
+	ID:          1,
+	UserID:      1,
+	Slug:        "onboarding",
+	Title:       "Onboarding",
+	Description: "Read *these* first",
+	Visibility:  models.VisibilityPublic,
+	Created:     time.Now(),
+	Snippets:    []*models.Snippet{mockSnippet, mockFork},
+}
+
+var mockPrivateCollection = &models.Collection{
+	ID:         2,
+	UserID:     1,
+	Slug:       "drafts",
+	Title:      "Drafts",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Snippets:   []*models.Snippet{},
+}
+
+// mockFullCollection has room for no more snippets, though every snippet in
+// it has expired.
+var mockFullCollection = &models.Collection{
+	ID:         3,
+	UserID:     1,
+	Slug:       "full",
+	Title:      "Full",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Snippets:   []*models.Snippet{},
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(userID int, slug, title, description, visibility string) (int, error) {
+	if slug == mockCollection.Slug || slug == mockPrivateCollection.Slug || slug == mockFullCollection.Slug {
+		return 0, models.ErrDuplicateSlug
+	}
+	return 3, nil
+}
+
+func (m *CollectionModel) Update(id int, title, description, visibility string) error {
+	return nil
+}
+
+func (m *CollectionModel) GetBySlug(slug string) (*models.Collection, error) {
+	switch slug {
+	case mockCollection.Slug:
+		return mockCollection, nil
+	case mockPrivateCollection.Slug:
+		return mockPrivateCollection, nil
+	case mockFullCollection.Slug:
+		return mockFullCollection, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CollectionModel) ByUser(userID int) ([]*models.Collection, error) {
+	if userID == 1 {
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	}
+	return []*models.Collection{}, nil
+}
+
+func (m *CollectionModel) Unavailable(id int) ([]*models.Snippet, error) {
+	if id == mockFullCollection.ID {
+		return []*models.Snippet{mockExpiredSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *CollectionModel) CountSnippets(id int) (int, error) {
+	switch id {
+	case mockCollection.ID:
+		return len(mockCollection.Snippets), nil
+	case mockFullCollection.ID:
+		return 50, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m *CollectionModel) AddSnippet(id, snippetID int) error {
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(id, snippetID int) error {
+	return nil
+}
+
+func (m *CollectionModel) Reorder(id int, snippetIDs []int) error {
+	return nil
+}
+
+func (m *CollectionModel) Delete(id int) error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    1,
+	Author:    "Alice",
+	File:      "haiku.txt",
+	LineStart: 1,
+	LineEnd:   1,
+	Body:      "What a *lovely* pond",
+	Created:   time.Now(),
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID int, file string, lineStart, lineEnd int, body string) (int, error) {
+	return 2, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case 1:
+		return mockComment, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) BySnippet(snippetID int) ([]*models.Comment, error) {
+	if snippetID == mockComment.SnippetID {
+		return []*models.Comment{mockComment}, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	return nil
+}
+
+func (m *CommentModel) SetHidden(id int, hidden bool) error {
+	return nil
+}
+
+func (m *CommentModel) Tombstone(id int) (bool, error) {
+	return false, nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	return nil
+}
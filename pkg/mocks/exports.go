@@ -0,0 +1,70 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// ExportModel keeps exports in memory, so that tests can follow one from
+// request to download.
+type ExportModel struct {
+	mu      sync.Mutex
+	exports map[string]*models.Export
+}
+
+func (m *ExportModel) Start(userID int, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.exports {
+		if e.UserID == userID && !e.Ready {
+			return models.ErrExportPending
+		}
+	}
+	if m.exports == nil {
+		m.exports = map[string]*models.Export{}
+	}
+	m.exports[name] = &models.Export{Name: name, UserID: userID, Created: time.Now()}
+	return nil
+}
+
+func (m *ExportModel) Finish(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.exports[name]; ok {
+		e.Ready = true
+	}
+	return nil
+}
+
+func (m *ExportModel) Get(name string) (*models.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.exports[name]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	c := *e
+	return &c, nil
+}
+
+func (m *ExportModel) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.exports, name)
+	return nil
+}
+
+func (m *ExportModel) DeleteExpired(lifetime time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for name, e := range m.exports {
+		if time.Since(e.Created) > lifetime {
+			delete(m.exports, name)
+			n++
+		}
+	}
+	return n, nil
+}
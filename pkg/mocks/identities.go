@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type IdentityModel struct{}
+
+func (m *IdentityModel) Authenticate(provider, subject, name, email string, emailVerified bool) (int, error) {
+	switch {
+	case email == "alice@example.com" && emailVerified:
+		return 1, nil
+	case email == "alice@example.com":
+		return 0, models.ErrDuplicateEmail
+	default:
+		return 0, models.ErrInvalidCredentials
+	}
+}
+
+// mockSubject is the subject the fake provider in oidctest logs in as. It is
+// linked to Alice.
+const mockSubject = "248289761001"
+
+func (m *IdentityModel) UserID(provider, subject string) (int, error) {
+	if subject == mockSubject {
+		return 1, nil
+	}
+	return 0, models.ErrNoRecord
+}
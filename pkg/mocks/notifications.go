@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// NotificationModel keeps notifications in memory so that tests can check
+// what was sent.
+type NotificationModel struct {
+	mu            sync.Mutex
+	notifications []*models.Notification
+}
+
+func (m *NotificationModel) Insert(userID int, message, link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifications = append(m.notifications, &models.Notification{
+		ID:      len(m.notifications) + 1,
+		UserID:  userID,
+		Message: message,
+		Link:    link,
+		Created: time.Now(),
+	})
+	return nil
+}
+
+func (m *NotificationModel) Latest(userID int) ([]*models.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	notifications := []*models.Notification{}
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		if n := m.notifications[i]; n.UserID == userID {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (m *NotificationModel) CountUnread(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.notifications {
+		if n.UserID == userID {
+			n.Read = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type RememberTokenModel struct{}
+
+func (m *RememberTokenModel) Issue(userID int, expires time.Time) (string, error) {
+	return "selector:validator", nil
+}
+
+func (m *RememberTokenModel) Rotate(token string, expires time.Time) (int, string, error) {
+	switch token {
+	case "selector:validator":
+		return 1, "selector2:validator2", nil
+	case "rotated:validator":
+		return 1, "", nil
+	case "stolen:validator":
+		return 1, "", models.ErrTokenReused
+	default:
+		return 0, "", models.ErrInvalidToken
+	}
+}
+
+func (m *RememberTokenModel) Revoke(token string) error {
+	return nil
+}
+
+func (m *RememberTokenModel) RevokeAll(userID int) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// ReportModel keeps reports in memory so that tests can follow them through
+// the moderation queue.
+type ReportModel struct {
+	mu      sync.Mutex
+	reports []*models.Report
+}
+
+func (m *ReportModel) Insert(snippetID, reporterID int, reason, details string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &models.Report{
+		ID:         len(m.reports) + 1,
+		SnippetID:  snippetID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Details:    details,
+		Status:     models.ReportOpen,
+		Created:    time.Now(),
+	}
+	if s, err := (&SnippetModel{}).Get(snippetID); err == nil {
+		r.SnippetTitle, r.AuthorID = s.Title, s.UserID
+	}
+	if u, err := (&UserModel{}).Get(reporterID); err == nil {
+		r.Reporter = u.Name
+	}
+	m.reports = append(m.reports, r)
+	return r.ID, nil
+}
+
+func (m *ReportModel) CountSince(reporterID int, t time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, r := range m.reports {
+		if r.ReporterID == reporterID && !r.Created.Before(t) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *ReportModel) Get(id int) (*models.Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.reports) {
+		return nil, models.ErrNoRecord
+	}
+	return m.reports[id-1], nil
+}
+
+func (m *ReportModel) Open(limit, offset int) ([]*models.Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	open := []*models.Report{}
+	for _, r := range m.reports {
+		if r.Status == models.ReportOpen {
+			open = append(open, r)
+		}
+	}
+	if offset > len(open) {
+		offset = len(open)
+	}
+	open = open[offset:]
+	if limit < len(open) {
+		open = open[:limit]
+	}
+	return open, nil
+}
+
+func (m *ReportModel) Resolve(snippetID int, status string, moderatorID int) ([]*models.Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved := []*models.Report{}
+	for _, r := range m.reports {
+		if r.SnippetID == snippetID && r.Status == models.ReportOpen {
+			r.Status, r.ResolvedBy, r.Resolved = status, moderatorID, time.Now()
+			resolved = append(resolved, r)
+		}
+	}
+	return resolved, nil
+}
@@ -9,26 +9,108 @@ import (
 var mockSnippet = &models.Snippet{
 	ID:      1,
 	Title:   "An old silent pond",
-	Content: "An old silent pond...",
+	Files:   []*models.File{{Name: "haiku.txt", Content: "An old silent pond..."}},
 	Created: time.Now(),
 	Expires: time.Now(),
+	Tags:    []string{"haiku", "poetry"},
+}
+
+var mockHiddenSnippet = &models.Snippet{
+	ID:      3,
+	Title:   "Spam",
+	Files:   []*models.File{{Name: "spam.txt", Content: "Buy now!"}},
+	Created: time.Now(),
+	Expires: time.Now(),
+	Hidden:  true,
+}
+
+var mockFork = &models.Snippet{
+	ID:    4,
+	Title: "An old silent pond",
+	Files: []*models.File{
+		{Name: "haiku.txt", Content: "An old silent pond...\nA frog jumps in"},
+		{Name: "notes.md", Content: "*Bashō*"},
+	},
+	Created:      time.Now(),
+	Expires:      time.Now(),
+	UserID:       1,
+	ForkedFromID: 1,
+}
+
+var mockExpiredSnippet = &models.Snippet{
+	ID:      5,
+	Title:   "Gone",
+	Files:   []*models.File{{Name: "gone.txt", Content: "Expired"}},
+	Created: time.Now().Add(-48 * time.Hour),
+	Expires: time.Now().Add(-24 * time.Hour),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, conetnt, expires string) (int, error) {
+func (m *SnippetModel) Insert(userID, forkedFromID int, title string, files []*models.File, tags []string, expires string) (int, error) {
 	return 2, nil
 }
 
+func (m *SnippetModel) Update(id int, title string, files []*models.File, tags []string) error {
+	return nil
+}
+
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockHiddenSnippet, nil
+	case 4:
+		return mockFork, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
+func (m *SnippetModel) GetAny(id int) (*models.Snippet, error) {
+	if id == mockExpiredSnippet.ID {
+		return mockExpiredSnippet, nil
+	}
+	return m.Get(id)
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	if snippetID != mockSnippet.ID {
+		return []*models.Revision{}, nil
+	}
+	return []*models.Revision{{
+		ID:       1,
+		Title:    "An old pond",
+		Files:    []*models.File{{Name: "haiku.txt", Content: "An old silent pond..."}},
+		Replaced: time.Now(),
+	}}, nil
+}
+
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) Search(query string, limit, offset int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Count() (*models.SnippetCounts, error) {
+	return &models.SnippetCounts{Total: 1, Live: 1}, nil
+}
+
+func (m *SnippetModel) SetHidden(id int, hidden bool) error {
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	return nil
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) PublicByUser(userID, limit, offset int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
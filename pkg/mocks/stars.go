@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type StarModel struct {
+	mu    sync.Mutex
+	stars map[[2]int]bool
+}
+
+func (m *StarModel) Toggle(userID, snippetID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stars == nil {
+		m.stars = map[[2]int]bool{}
+	}
+	key := [2]int{userID, snippetID}
+	m.stars[key] = !m.stars[key]
+	return m.stars[key], nil
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stars[[2]int{userID, snippetID}], nil
+}
+
+func (m *StarModel) ByUser(userID int) ([]*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stars[[2]int{userID, mockSnippet.ID}] {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *StarModel) MostStarred(since time.Time, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
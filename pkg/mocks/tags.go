@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) Snippets(tag string, limit, offset int) ([]*models.Snippet, error) {
+	for _, t := range mockSnippet.Tags {
+		if t == tag {
+			return []*models.Snippet{mockSnippet}, nil
+		}
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *TagModel) Popular(limit int) ([]*models.Tag, error) {
+	return []*models.Tag{{Name: "haiku", Count: 3}, {Name: "poetry", Count: 1}}, nil
+}
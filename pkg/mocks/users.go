@@ -7,19 +7,46 @@ import (
 )
 
 var mockUser = &models.User{
-	ID:      1,
-	Name:    "Alice",
-	Email:   "alice@example.com",
-	Created: time.Now(),
-	Active:  true,
+	ID:            1,
+	Name:          "Alice",
+	Username:      "alice",
+	Email:         "alice@example.com",
+	Created:       time.Now(),
+	Active:        true,
+	Role:          models.RoleUser,
+	ProfilePublic: true,
+}
+
+var mockResetUser = &models.User{
+	ID:                    3,
+	Name:                  "Reset",
+	Username:              "reset",
+	Email:                 "reset@example.com",
+	Created:               time.Now(),
+	Active:                true,
+	Role:                  models.RoleUser,
+	PasswordResetRequired: true,
+	ProfilePublic:         true,
+}
+
+var mockAdmin = &models.User{
+	ID:       2,
+	Name:     "Root",
+	Username: "root",
+	Email:    "root@example.com",
+	Created:  time.Now(),
+	Active:   true,
+	Role:     models.RoleAdmin,
 }
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
-	switch email {
-	case "dupe@example.com":
+func (m *UserModel) Insert(name, username, email, password string) error {
+	switch {
+	case email == "dupe@example.com":
 		return models.ErrDuplicateEmail
+	case username == "taken":
+		return models.ErrDuplicateUsername
 	default:
 		return nil
 	}
@@ -29,6 +56,10 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	switch email {
 	case "alice@example.com":
 		return 1, nil
+	case "root@example.com":
+		return 2, nil
+	case "reset@example.com":
+		return 3, nil
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
@@ -38,11 +69,62 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockAdmin, nil
+	case 3:
+		return mockResetUser, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
+func (m *UserModel) Search(query string, limit, offset int) ([]*models.User, error) {
+	return []*models.User{mockUser, mockAdmin}, nil
+}
+
+func (m *UserModel) Count() (*models.UserCounts, error) {
+	return &models.UserCounts{Total: 3, Active: 3}, nil
+}
+
+func (m *UserModel) SetActive(id int, active bool) error {
+	return nil
+}
+
+func (m *UserModel) RequirePasswordReset(id int) error {
+	return nil
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	if !models.ValidRole(role) {
+		return models.ErrInvalidRole
+	}
+	return nil
+}
+
 func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
 	return nil
 }
+
+func (m *UserModel) Deactivate(id int) error {
+	return nil
+}
+
+func (m *UserModel) ScheduleDeletion(id int, after time.Time, deleteSnippets bool) error {
+	return nil
+}
+
+func (m *UserModel) GetByUsername(username string) (*models.User, error) {
+	for _, u := range []*models.User{mockUser, mockAdmin, mockResetUser} {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) UpdateProfile(id int, username string, public bool) error {
+	if username == "taken" {
+		return models.ErrDuplicateUsername
+	}
+	return nil
+}
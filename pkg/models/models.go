@@ -9,21 +9,264 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrDuplicateUsername  = errors.New("models: duplicate username")
+	ErrInvalidToken       = errors.New("models: invalid token")
+	ErrTokenReused        = errors.New("models: token reused")
+	ErrInvalidRole        = errors.New("models: invalid role")
+	ErrDuplicateSlug      = errors.New("models: duplicate slug")
+	ErrNoMasterKeys       = errors.New("models: no master keys for encrypted snippets")
+	ErrExportPending      = errors.New("models: export pending")
 )
 
+// Roles are ordered, each one has every permission of the roles before it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+type Permission string
+
+const (
+	PermAccessAdmin      Permission = "admin:access"
+	PermModerateSnippets Permission = "snippets:moderate"
+	PermManageUsers      Permission = "users:manage"
+	PermManageRoles      Permission = "roles:manage"
+	PermViewAuditLog     Permission = "audit:view"
+)
+
+// rolePermissions lists what each role adds on top of the roles below it.
+var rolePermissions = map[string][]Permission{
+	RoleModerator: {PermAccessAdmin, PermModerateSnippets},
+	RoleAdmin:     {PermManageUsers, PermManageRoles, PermViewAuditLog},
+}
+
+func roleLevel(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+func ValidRole(role string) bool {
+	return roleLevel(role) >= 0
+}
+
+// Snippet is a titled set of files. Only Get loads the files and tags, and
+// ByUser for exports; lists of snippets leave them empty.
 type Snippet struct {
 	ID      int
 	Title   string
-	Content string
+	Files   []*File
 	Created time.Time
 	Expires time.Time
+	Hidden  bool
+	// UserID is the owner, or zero for anonymous snippets.
+	UserID int
+	Stars  int
+	// ForkedFromID is the snippet this one was forked from, or zero.
+	ForkedFromID int
+	Forks        int
+	Tags         []string
+}
+
+// File is a named file of a snippet. Names are unique within a snippet.
+type File struct {
+	Name    string
+	Content string
+}
+
+// Revision is a version of a snippet that was replaced by an edit.
+type Revision struct {
+	ID       int
+	Title    string
+	Files    []*File
+	Replaced time.Time
+}
+
+// File returns the file of s with the given name, or nil.
+func (s *Snippet) File(name string) *File {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Tag is a tag with the number of live snippets that have it.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+type SnippetCounts struct {
+	Total  int
+	Live   int
+	Hidden int
 }
 
 type User struct {
-	ID             int
-	Name           string
+	ID   int
+	Name string
+	// Username is the handle in the user's public profile URL. It is empty
+	// for users who signed up through single sign-on and haven't chosen one.
+	Username       string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
 	Active         bool
+	Role           string
+	// PasswordResetRequired is set by an admin to make the user change their
+	// password before they can do anything else.
+	PasswordResetRequired bool
+	// ProfilePublic lets anyone see the user's profile at /u/{username}.
+	ProfilePublic bool
+}
+
+type UserCounts struct {
+	Total  int
+	Active int
+}
+
+// HasRole reports whether u has role or a role above it.
+func (u *User) HasRole(role string) bool {
+	if u == nil || !ValidRole(role) {
+		return false
+	}
+	return roleLevel(u.Role) >= roleLevel(role)
+}
+
+// Can reports whether u has been granted permission through their role.
+func (u *User) Can(permission Permission) bool {
+	if u == nil {
+		return false
+	}
+	for _, role := range Roles {
+		if !u.HasRole(role) {
+			break
+		}
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Notification tells a user about something that happened while they were
+// away, such as their data export being ready. Link is optional.
+type Notification struct {
+	ID      int
+	UserID  int
+	Message string
+	Link    string
+	Created time.Time
+	Read    bool
+}
+
+// Export is a data export archive, named after its file. It isn't Ready
+// while it's being built.
+type Export struct {
+	Name    string
+	UserID  int
+	Created time.Time
+	Ready   bool
+}
+
+// Collections are public, listed on their owner's profile; unlisted, seen by
+// anyone with the link; or private, seen only by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// Collection is an ordered list of snippets curated by a user, shown at
+// /c/{slug}.
+type Collection struct {
+	ID          int
+	UserID      int
+	Slug        string
+	Title       string
+	Description string
+	Visibility  string
+	Created     time.Time
+	// Snippets are the live snippets in the collection, in order, with their
+	// files. Only GetBySlug loads them.
+	Snippets []*Snippet
+}
+
+// Comment is a comment on a snippet. Threads are one level deep: a reply to
+// a reply joins the same thread, and replies share the anchor of the comment
+// that started it.
+type Comment struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	Author    string
+	// ParentID is the comment that started the thread, or zero.
+	ParentID int
+	// File, LineStart and LineEnd anchor the comment to a range of lines of
+	// a file. They are empty for comments on the whole snippet.
+	File      string
+	LineStart int
+	LineEnd   int
+	Body      string
+	Created   time.Time
+	// Edited is zero if the comment hasn't been edited.
+	Edited time.Time
+	// Hidden comments have been hidden by the snippet owner or a moderator.
+	Hidden bool
+	// Deleted comments were deleted by their author but kept, without their
+	// body, for the replies to them.
+	Deleted bool
+	Replies []*Comment
+}
+
+// Reasons a snippet can be reported for.
+const (
+	ReasonSpam         = "spam"
+	ReasonAbuse        = "abuse"
+	ReasonMalware      = "malware"
+	ReasonPersonalData = "personal data"
+	ReasonCopyright    = "copyright"
+	ReasonOther        = "other"
+)
+
+var ReportReasons = []string{ReasonSpam, ReasonAbuse, ReasonMalware, ReasonPersonalData, ReasonCopyright, ReasonOther}
+
+// A report is open until a moderator hides the snippet, dismisses the report
+// or deactivates the author, which also hides the snippet.
+const (
+	ReportOpen        = "open"
+	ReportHidden      = "hidden"
+	ReportDismissed   = "dismissed"
+	ReportDeactivated = "deactivated"
+)
+
+// Report is a user's complaint about a snippet, waiting in the moderation
+// queue until it's resolved.
+type Report struct {
+	ID         int
+	SnippetID  int
+	ReporterID int
+	Reason     string
+	Details    string
+	Status     string
+	Created    time.Time
+	// ResolvedBy is the moderator who resolved the report, or zero.
+	ResolvedBy int
+	Resolved   time.Time
+	// SnippetTitle, AuthorID and Reporter are filled in by Open.
+	SnippetTitle string
+	AuthorID     int
+	Reporter     string
 }
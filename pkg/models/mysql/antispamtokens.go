@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"time"
+)
+
+// AntispamTokenModel is an antispam.Store backed by the antispam_tokens
+// table, so that a form submitted to one instance can't be replayed against
+// another. Only a SHA-256 hash of each token is stored.
+type AntispamTokenModel struct {
+	DB *sql.DB
+}
+
+func (m *AntispamTokenModel) Use(token string, expires time.Time) (bool, error) {
+	hash := sha256.Sum256([]byte(token))
+	stmt := `insert into antispam_tokens (token_hash, expires) values (?, ?)`
+	if _, err := m.DB.Exec(stmt, hash[:], expires.UTC()); err != nil {
+		if isDuplicate(err, "PRIMARY") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpired forgets the tokens that would be refused as expired anyway,
+// and returns how many there were.
+func (m *AntispamTokenModel) DeleteExpired() (int, error) {
+	result, err := m.DB.Exec(`delete from antispam_tokens where expires < UTC_TIMESTAMP()`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
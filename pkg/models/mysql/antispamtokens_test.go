@@ -0,0 +1,39 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_AntispamTokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := AntispamTokenModel{DB: db}
+	expires := time.Now().Add(time.Hour)
+
+	for _, want := range []bool{true, false} {
+		ok, err := m.Use("1622548800.salt.signature", expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("want %v; got %v", want, ok)
+		}
+	}
+
+	if _, err := m.Use("1622548800.other.signature", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 expired token; got %d", n)
+	}
+}
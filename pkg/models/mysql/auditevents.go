@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+)
+
+// AuditEventModel is an audit.Store backed by the audit_events table.
+type AuditEventModel struct {
+	DB *sql.DB
+}
+
+func (m *AuditEventModel) Insert(e *audit.Event) error {
+	var actorID sql.NullInt64
+	if e.ActorID > 0 {
+		actorID = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+	var details []byte
+	if len(e.Details) > 0 {
+		var err error
+		details, err = json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+	}
+	stmt := `insert into audit_events (created, action, actor_id, ip, user_agent, details) values (?, ?, ?, ?, ?, ?)`
+	result, err := m.DB.Exec(stmt, e.Time.UTC(), e.Action, actorID, e.IP, truncate(e.UserAgent, 255), details)
+	if err != nil {
+		return err
+	}
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+func (m *AuditEventModel) List(f audit.Filter) ([]*audit.Event, error) {
+	where := []string{"true"}
+	args := []interface{}{}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, f.ActorID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.Until.UTC())
+	}
+	stmt := `select id, created, action, coalesce(actor_id, 0), ip, user_agent, details from audit_events where ` + strings.Join(where, " and ") + ` order by id desc`
+	if f.Limit > 0 {
+		stmt += ` limit ? offset ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*audit.Event{}
+	for rows.Next() {
+		e := &audit.Event{}
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Time, &e.Action, &e.ActorID, &e.IP, &e.UserAgent, &details); err != nil {
+			return nil, err
+		}
+		if details != nil {
+			if err := json.Unmarshal(details, &e.Details); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
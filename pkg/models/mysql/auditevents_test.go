@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+)
+
+func Test_AuditEventModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := AuditEventModel{db}
+	now := time.Now()
+
+	for _, e := range []*audit.Event{
+		{Time: now, Action: audit.ActionLoginFailed, IP: "203.0.113.7", Details: audit.Details{"email": "alice@example.com"}},
+		{Time: now, Action: audit.ActionLogin, ActorID: 1, IP: "203.0.113.7"},
+	} {
+		if err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := m.List(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != audit.ActionLogin {
+		t.Fatalf("want the newest event first; got %+v", events)
+	}
+	if got := events[1].Details["email"]; got != "alice@example.com" {
+		t.Errorf("want %q; got %v", "alice@example.com", got)
+	}
+
+	events, err = m.List(audit.Filter{ActorID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("want 1; got %d", len(events))
+	}
+
+	_, err = db.Exec(`delete from audit_events`)
+	if err == nil {
+		t.Error("want deleting audit events to fail")
+	}
+}
@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/envelope"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// CollectionModel stores collections. Keys decrypts the files of their
+// snippets, as in SnippetModel.
+type CollectionModel struct {
+	DB   *sql.DB
+	Keys *envelope.Keyring
+}
+
+func (m *CollectionModel) Insert(userID int, slug, title, description, visibility string) (int, error) {
+	stmt := `insert into collections (user_id, slug, title, description, visibility, created)
+	values (?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, userID, slug, title, description, visibility)
+	if err != nil {
+		if isDuplicate(err, "collections_uc_slug") {
+			return 0, models.ErrDuplicateSlug
+		}
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (m *CollectionModel) Update(id int, title, description, visibility string) error {
+	stmt := `update collections set title = ?, description = ?, visibility = ? where id = ?`
+	_, err := m.DB.Exec(stmt, title, description, visibility, id)
+	return err
+}
+
+const collectionColumns = `id, user_id, slug, title, description, visibility, created`
+
+func scanCollection(row scanner) (*models.Collection, error) {
+	c := &models.Collection{}
+	err := row.Scan(&c.ID, &c.UserID, &c.Slug, &c.Title, &c.Description, &c.Visibility, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetBySlug returns a collection with its live snippets, in order.
+func (m *CollectionModel) GetBySlug(slug string) (*models.Collection, error) {
+	stmt := `select ` + collectionColumns + ` from collections where slug = ?`
+	c, err := scanCollection(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `select ` + snippetColumns + ` from snippets join collection_snippets cs on cs.snippet_id = snippets.id
+	where cs.collection_id = ? and snippets.expires > UTC_TIMESTAMP() and not snippets.hidden
+	order by cs.position, cs.snippet_id`
+	c.Snippets, err = querySnippets(m.DB, stmt, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return c, loadFiles(m.DB, m.Keys, c.Snippets...)
+}
+
+// ByUser returns the collections of a user, by title.
+func (m *CollectionModel) ByUser(userID int) ([]*models.Collection, error) {
+	stmt := `select ` + collectionColumns + ` from collections where user_id = ? order by title, id`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// Unavailable returns the snippets in a collection that have expired or been
+// hidden, without their files, so that the owner can remove them.
+func (m *CollectionModel) Unavailable(id int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets join collection_snippets cs on cs.snippet_id = snippets.id
+	where cs.collection_id = ? and (snippets.expires <= UTC_TIMESTAMP() or snippets.hidden)
+	order by cs.position, cs.snippet_id`
+	return querySnippets(m.DB, stmt, id)
+}
+
+// CountSnippets returns how many snippets are in a collection, including
+// expired and hidden ones that GetBySlug leaves out.
+func (m *CollectionModel) CountSnippets(id int) (int, error) {
+	var n int
+	stmt := `select count(*) from collection_snippets where collection_id = ?`
+	err := m.DB.QueryRow(stmt, id).Scan(&n)
+	return n, err
+}
+
+// AddSnippet puts a snippet at the end of a collection, unless it is
+// already in it.
+func (m *CollectionModel) AddSnippet(id, snippetID int) error {
+	stmt := `insert ignore into collection_snippets (collection_id, snippet_id, position)
+	select ?, ?, coalesce(max(position), 0) + 1 from collection_snippets where collection_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID, id)
+	return err
+}
+
+func (m *CollectionModel) RemoveSnippet(id, snippetID int) error {
+	stmt := `delete from collection_snippets where collection_id = ? and snippet_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID)
+	return err
+}
+
+// Reorder puts the given snippets of a collection in that order.
+func (m *CollectionModel) Reorder(id int, snippetIDs []int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `update collection_snippets set position = ? where collection_id = ? and snippet_id = ?`
+	for i, snippetID := range snippetIDs {
+		if _, err := tx.Exec(stmt, i+1, id, snippetID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *CollectionModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from collection_snippets where collection_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from collections where id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
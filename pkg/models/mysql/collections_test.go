@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_CollectionModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CollectionModel{DB: db}
+	snippets := SnippetModel{DB: db}
+	files := []*models.File{{Name: "a.txt", Content: "Content"}}
+
+	var ids []int
+	for _, title := range []string{"First", "Second", "Third"} {
+		id, err := snippets.Insert(1, 0, title, files, nil, "7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	id, err := m.Insert(1, "onboarding", "Onboarding", "Read these first", models.VisibilityPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Insert(1, "onboarding", "Again", "", models.VisibilityPrivate)
+	if !errors.Is(err, models.ErrDuplicateSlug) {
+		t.Errorf("want %v; got %v", models.ErrDuplicateSlug, err)
+	}
+
+	for _, snippetID := range append(ids, ids[0]) {
+		if err := m.AddSnippet(id, snippetID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RemoveSnippet(id, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reorder(id, []int{ids[2], ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := m.GetBySlug("onboarding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Snippets) != 2 || c.Snippets[0].ID != ids[2] || c.Snippets[1].ID != ids[0] {
+		t.Fatalf("want snippets %d and %d; got %v", ids[2], ids[0], c.Snippets)
+	}
+	if len(c.Snippets[0].Files) != 1 {
+		t.Errorf("want the files loaded; got %v", c.Snippets[0].Files)
+	}
+
+	if _, err := db.Exec(`update snippets set expires = UTC_TIMESTAMP() - interval 1 day where id = ?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.CountSnippets(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want the expired snippet counted too; got %d", n)
+	}
+	unavailable, err := m.Unavailable(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unavailable) != 1 || unavailable[0].ID != ids[0] {
+		t.Errorf("want snippet %d unavailable; got %v", ids[0], unavailable)
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.GetBySlug("onboarding")
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a comment. parentID, file, lineStart and lineEnd are empty
+// when they don't apply.
+func (m *CommentModel) Insert(snippetID, userID, parentID int, file string, lineStart, lineEnd int, body string) (int, error) {
+	stmt := `insert into comments (snippet_id, user_id, parent_id, file, line_start, line_end, body, created)
+	values (?, ?, nullif(?, 0), ?, nullif(?, 0), nullif(?, 0), ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, snippetID, userID, parentID, file, lineStart, lineEnd, body)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+const commentColumns = `c.id, c.snippet_id, c.user_id, coalesce(u.name, ''), coalesce(c.parent_id, 0),
+	c.file, coalesce(c.line_start, 0), coalesce(c.line_end, 0), c.body, c.created, c.edited, c.hidden, c.deleted`
+
+func scanComment(row scanner) (*models.Comment, error) {
+	c := &models.Comment{}
+	var edited sql.NullTime
+	err := row.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.ParentID,
+		&c.File, &c.LineStart, &c.LineEnd, &c.Body, &c.Created, &edited, &c.Hidden, &c.Deleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	c.Edited = edited.Time
+	return c, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `select ` + commentColumns + ` from comments c left join users u on u.id = c.user_id where c.id = ?`
+	return scanComment(m.DB.QueryRow(stmt, id))
+}
+
+// BySnippet returns the threads on a snippet, oldest first, with their
+// replies.
+func (m *CommentModel) BySnippet(snippetID int) ([]*models.Comment, error) {
+	stmt := `select ` + commentColumns + ` from comments c left join users u on u.id = c.user_id
+	where c.snippet_id = ? order by c.created, c.id`
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []*models.Comment{}
+	byID := map[int]*models.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ParentID == 0 {
+			threads = append(threads, c)
+			byID[c.ID] = c
+		} else if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	stmt := `update comments set body = ?, edited = ? where id = ?`
+	_, err := m.DB.Exec(stmt, body, time.Now().UTC(), id)
+	return err
+}
+
+func (m *CommentModel) SetHidden(id int, hidden bool) error {
+	stmt := `update comments set hidden = ? where id = ?`
+	_, err := m.DB.Exec(stmt, hidden, id)
+	return err
+}
+
+// Tombstone clears the body of a comment that has replies, so that the
+// thread can be read without it. It reports false and changes nothing if
+// the comment has no replies.
+func (m *CommentModel) Tombstone(id int) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var replies int
+	stmt := `select count(*) from comments where parent_id = ? for update`
+	if err := tx.QueryRow(stmt, id).Scan(&replies); err != nil {
+		return false, err
+	}
+	if replies == 0 {
+		return false, nil
+	}
+	stmt = `update comments set body = '', deleted = true where id = ?`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Delete removes a comment and, if it started a thread, its replies.
+func (m *CommentModel) Delete(id int) error {
+	stmt := `delete from comments where id = ? or parent_id = ?`
+	_, err := m.DB.Exec(stmt, id, id)
+	return err
+}
+
+// detachComments clears the file and lines of the comments on the given files
+// of a snippet, whose lines may have moved, so they become comments on the
+// whole snippet.
+func detachComments(tx *sql.Tx, snippetID int, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	args := []interface{}{snippetID}
+	for _, f := range files {
+		args = append(args, f)
+	}
+	stmt := `update comments set file = '', line_start = null, line_end = null
+	where snippet_id = ? and file in (?` + strings.Repeat(", ?", len(files)-1) + `)`
+	_, err := tx.Exec(stmt, args...)
+	return err
+}
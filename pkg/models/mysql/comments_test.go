@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_CommentModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CommentModel{db}
+	files := []*models.File{{Name: "review.txt", Content: "line one\nline two"}}
+	snippetID, err := (&SnippetModel{DB: db}).Insert(1, 0, "Reviewed", files, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threadID, err := m.Insert(snippetID, 1, 0, "review.txt", 1, 2, "Why two lines?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(snippetID, 1, threadID, "review.txt", 1, 2, "Never mind"); err != nil {
+		t.Fatal(err)
+	}
+	lonelyID, err := m.Insert(snippetID, 1, 0, "", 0, 0, "Looks good")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threads, err := m.BySnippet(snippetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("want 2 threads; got %d", len(threads))
+	}
+	first := threads[0]
+	if first.Author != "Alice Jones" || first.File != "review.txt" || first.LineStart != 1 || first.LineEnd != 2 {
+		t.Errorf("want Alice's comment on lines 1-2 of review.txt; got %+v", first)
+	}
+	if len(first.Replies) != 1 || first.Replies[0].Body != "Never mind" {
+		t.Errorf("want one reply; got %v", first.Replies)
+	}
+
+	if err := m.Update(threadID, "Why?"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Get(threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Body != "Why?" || c.Edited.IsZero() {
+		t.Errorf("want the comment to be edited; got %+v", c)
+	}
+
+	files[0].Content = "line zero\nline one\nline two"
+	if err := (&SnippetModel{DB: db}).Update(snippetID, "Reviewed", files, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, err = m.Get(threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.File != "" || c.LineStart != 0 || c.LineEnd != 0 {
+		t.Errorf("want the comment moved off review.txt; got %+v", c)
+	}
+
+	if kept, err := m.Tombstone(lonelyID); err != nil || kept {
+		t.Errorf("want no tombstone for a comment without replies; got %v, %v", kept, err)
+	}
+	if kept, err := m.Tombstone(threadID); err != nil || !kept {
+		t.Fatalf("want a tombstone; got %v, %v", kept, err)
+	}
+	c, err = m.Get(threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Deleted || c.Body != "" {
+		t.Errorf("want the body cleared; got %+v", c)
+	}
+
+	if err := m.Delete(threadID); err != nil {
+		t.Fatal(err)
+	}
+	threads, err = m.BySnippet(snippetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || len(threads[0].Replies) != 0 {
+		t.Errorf("want the thread and its reply to be deleted; got %v", threads)
+	}
+}
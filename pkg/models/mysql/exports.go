@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type ExportModel struct {
+	DB *sql.DB
+}
+
+// Start records an export that is about to be built, unless the user has one
+// pending. Pending exports older than an hour are taken to have died with the
+// server that was building them.
+func (m *ExportModel) Start(userID int, name string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the user makes requests made at the same time wait their turn.
+	var id int
+	if err := tx.QueryRow(`select id from users where id = ? for update`, userID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+	var pending int
+	stmt := `select count(*) from exports where user_id = ? and not ready and created > UTC_TIMESTAMP() - interval 1 hour`
+	if err := tx.QueryRow(stmt, userID).Scan(&pending); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return models.ErrExportPending
+	}
+	stmt = `insert into exports (name, user_id, created) values (?, ?, UTC_TIMESTAMP())`
+	if _, err := tx.Exec(stmt, name, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Finish marks an export as ready to download.
+func (m *ExportModel) Finish(name string) error {
+	_, err := m.DB.Exec(`update exports set ready = true where name = ?`, name)
+	return err
+}
+
+func (m *ExportModel) Get(name string) (*models.Export, error) {
+	stmt := `select name, user_id, created, ready from exports where name = ?`
+	e := &models.Export{}
+	err := m.DB.QueryRow(stmt, name).Scan(&e.Name, &e.UserID, &e.Created, &e.Ready)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (m *ExportModel) Delete(name string) error {
+	_, err := m.DB.Exec(`delete from exports where name = ?`, name)
+	return err
+}
+
+// DeleteExpired deletes the exports made more than lifetime ago and returns
+// how many there were.
+func (m *ExportModel) DeleteExpired(lifetime time.Duration) (int, error) {
+	result, err := m.DB.Exec(`delete from exports where created < ?`, time.Now().Add(-lifetime).UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,54 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_ExportModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := ExportModel{DB: db}
+	first := "00000000000000000000000000000001.zip"
+	if err := m.Start(1, first); err != nil {
+		t.Fatal(err)
+	}
+	err := m.Start(1, "00000000000000000000000000000002.zip")
+	if !errors.Is(err, models.ErrExportPending) {
+		t.Errorf("want %v; got %v", models.ErrExportPending, err)
+	}
+
+	if err := m.Finish(first); err != nil {
+		t.Fatal(err)
+	}
+	e, err := m.Get(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.UserID != 1 || !e.Ready {
+		t.Errorf("want a ready export for user 1; got %+v", e)
+	}
+	if err := m.Start(1, "00000000000000000000000000000003.zip"); err != nil {
+		t.Errorf("want a new export once the last is ready; got %v", err)
+	}
+
+	users := UserModel{db}
+	if err := users.ScheduleDeletion(1, time.Now().Add(-time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.PurgeDeleted(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Get(first)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want the exports of a purged user deleted; got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// IdentityModel links users to accounts at external identity providers.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// Authenticate returns the user linked to subject at provider, creating the
+// user on first login and syncing their name and email on later ones. An
+// existing local account with the same email is only linked if the provider
+// has verified the address, otherwise ErrDuplicateEmail is returned.
+func (m *IdentityModel) Authenticate(provider, subject, name, email string, emailVerified bool) (int, error) {
+	if email == "" {
+		return 0, models.ErrInvalidCredentials
+	}
+	if name == "" {
+		name = email
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var active bool
+	// Like a password login, signing in again undoes a deactivation the user
+	// made themselves.
+	stmt := `select u.id, u.active or u.self_deactivated from user_identities i join users u on u.id = i.user_id where i.provider = ? and i.subject = ?`
+	err = tx.QueryRow(stmt, provider, subject).Scan(&id, &active)
+	if err == nil {
+		if !active {
+			return 0, models.ErrInvalidCredentials
+		}
+		stmt = `update users set name = ?, email = ?, active = true, self_deactivated = false, delete_after = null where id = ?`
+		if _, err := tx.Exec(stmt, name, email, id); err != nil {
+			if isDuplicateEmail(err) {
+				return 0, models.ErrDuplicateEmail
+			}
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		return id, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	stmt = `select id, active from users where email = ?`
+	err = tx.QueryRow(stmt, email).Scan(&id, &active)
+	if err == nil {
+		if !emailVerified {
+			return 0, models.ErrDuplicateEmail
+		}
+		if !active {
+			return 0, models.ErrInvalidCredentials
+		}
+	} else if errors.Is(err, sql.ErrNoRows) {
+		id, err = m.insertUser(tx, name, email)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		return 0, err
+	}
+
+	stmt = `insert into user_identities (user_id, provider, subject, created) values (?, ?, ?, UTC_TIMESTAMP())`
+	if _, err := tx.Exec(stmt, id, provider, subject); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UserID returns the user linked to subject at provider, or ErrNoRecord if
+// there is none.
+func (m *IdentityModel) UserID(provider, subject string) (int, error) {
+	var id int
+	stmt := `select user_id from user_identities where provider = ? and subject = ?`
+	err := m.DB.QueryRow(stmt, provider, subject).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.ErrNoRecord
+	}
+	return id, err
+}
+
+// insertUser creates a user that can only log in through a provider. Its
+// password is random and never revealed.
+func (m *IdentityModel) insertUser(tx *sql.Tx, name, email string) (int, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return 0, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword(password, 12)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into users (name, email, hashed_password, created) values (?, ?, ?, UTC_TIMESTAMP())`
+	result, err := tx.Exec(stmt, name, email, string(hashedPassword))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
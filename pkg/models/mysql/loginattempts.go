@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LoginAttemptModel is a throttle.Store backed by the login_attempts table, so
+// that failed attempts are shared between every running instance.
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+func (m *LoginAttemptModel) Get(key string) (int, time.Time, error) {
+	var failures int
+	var last time.Time
+	stmt := `select failures, last_failure from login_attempts where attempt_key = ?`
+	err := m.DB.QueryRow(stmt, key).Scan(&failures, &last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, time.Time{}, nil
+		} else {
+			return 0, time.Time{}, err
+		}
+	}
+	return failures, last, nil
+}
+
+func (m *LoginAttemptModel) Increment(key string, now time.Time, window time.Duration) (int, error) {
+	now = now.UTC()
+	staleBefore := now.Add(-window)
+	stmt := `insert into login_attempts (attempt_key, failures, last_failure) values (?, 1, ?)
+	on duplicate key update failures = if(last_failure < ?, 1, failures + 1), last_failure = values(last_failure)`
+	_, err := m.DB.Exec(stmt, key, now, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	failures, _, err := m.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (m *LoginAttemptModel) Reset(key string) error {
+	stmt := `delete from login_attempts where attempt_key = ?`
+	_, err := m.DB.Exec(stmt, key)
+	return err
+}
@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+func (m *NotificationModel) Insert(userID int, message, link string) error {
+	stmt := `insert into notifications (user_id, message, link, created) values (?, ?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, truncate(message, 255), link)
+	return err
+}
+
+// Latest returns the user's 50 most recent notifications.
+func (m *NotificationModel) Latest(userID int) ([]*models.Notification, error) {
+	stmt := `select id, user_id, message, link, created, is_read from notifications where user_id = ? order by id desc limit 50`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		n := &models.Notification{}
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Message, &n.Link, &n.Created, &n.Read); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (m *NotificationModel) CountUnread(userID int) (int, error) {
+	var n int
+	stmt := `select count(*) from notifications where user_id = ? and is_read = false`
+	err := m.DB.QueryRow(stmt, userID).Scan(&n)
+	return n, err
+}
+
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	stmt := `update notifications set is_read = true where user_id = ? and is_read = false`
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RateLimitModel is a ratelimit.Store backed by the rate_limits table, so
+// that every running instance takes from the same buckets.
+type RateLimitModel struct {
+	DB *sql.DB
+}
+
+func (m *RateLimitModel) Update(key string, ttl time.Duration, fn func(float64, time.Time) (float64, time.Time)) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The row is created first, so that the select below locks it even when
+	// the key is new and two instances race for it.
+	stmt := `insert ignore into rate_limits (bucket_key, tokens, expires) values (?, 0, UTC_TIMESTAMP())`
+	if _, err := tx.Exec(stmt, key); err != nil {
+		return err
+	}
+	var tokens float64
+	var updated sql.NullTime
+	stmt = `select tokens, updated from rate_limits where bucket_key = ? for update`
+	if err := tx.QueryRow(stmt, key).Scan(&tokens, &updated); err != nil {
+		return err
+	}
+
+	tokens, last := fn(tokens, updated.Time)
+	stmt = `update rate_limits set tokens = ?, updated = ?, expires = ? where bucket_key = ?`
+	if _, err := tx.Exec(stmt, tokens, last.UTC(), last.Add(ttl).UTC(), key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteExpired forgets the buckets that have refilled since they were last
+// used, and returns how many there were.
+func (m *RateLimitModel) DeleteExpired() (int, error) {
+	result, err := m.DB.Exec(`delete from rate_limits where expires < UTC_TIMESTAMP()`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RateLimitModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := RateLimitModel{DB: db}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	var gotUpdated time.Time
+	err := m.Update("ip:203.0.113.7", time.Minute, func(tokens float64, updated time.Time) (float64, time.Time) {
+		gotUpdated = updated
+		return 2.5, now
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotUpdated.IsZero() {
+		t.Errorf("want a zero time for a new key; got %v", gotUpdated)
+	}
+
+	var gotTokens float64
+	err = m.Update("ip:203.0.113.7", time.Minute, func(tokens float64, updated time.Time) (float64, time.Time) {
+		gotTokens, gotUpdated = tokens, updated
+		return tokens - 1, now
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotTokens != 2.5 || !gotUpdated.Equal(now) {
+		t.Errorf("want 2.5 tokens at %v; got %v at %v", now, gotTokens, gotUpdated)
+	}
+
+	err = m.Update("ip:203.0.113.8", time.Minute, func(tokens float64, updated time.Time) (float64, time.Time) {
+		return 0, now.Add(-time.Hour)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 expired bucket; got %d", n)
+	}
+}
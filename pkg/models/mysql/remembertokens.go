@@ -0,0 +1,154 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// RememberTokenModel manages "remember me" tokens. A token has the form
+// selector:validator. The selector is stored as is and used to look the token
+// up, only a SHA-256 hash of the validator is stored.
+//
+// Every use rotates the token: the old row is marked used and a new one is
+// issued in the same family. A used token being presented again means it was
+// copied, so the whole family is revoked. The exception is the Grace period
+// after rotation, when requests the browser sent at the same time still carry
+// the old token.
+type RememberTokenModel struct {
+	DB    *sql.DB
+	Grace time.Duration
+}
+
+// Issue starts a new token family for userID and returns the token.
+func (m *RememberTokenModel) Issue(userID int, expires time.Time) (string, error) {
+	family, err := randomString(12)
+	if err != nil {
+		return "", err
+	}
+	return m.insert(m.DB, userID, family, expires)
+}
+
+// Rotate checks token and exchanges it for a new one in the same family. It
+// returns the ID of the user the token belongs to and the new token. A token
+// used within the grace period is accepted without being rotated again, and
+// no new token is returned, so the browser keeps the one it was just given.
+func (m *RememberTokenModel) Rotate(token string, expires time.Time) (int, string, error) {
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 {
+		return 0, "", models.ErrInvalidToken
+	}
+	selector, validator := parts[0], parts[1]
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	var family string
+	var hashedValidator []byte
+	var used bool
+	var usedAt sql.NullTime
+	stmt := `select id, user_id, family, hashed_validator, used, used_at from remember_tokens where selector = ? and expires > UTC_TIMESTAMP() for update`
+	err = tx.QueryRow(stmt, selector).Scan(&id, &userID, &family, &hashedValidator, &used, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", models.ErrInvalidToken
+		} else {
+			return 0, "", err
+		}
+	}
+
+	hash := sha256.Sum256([]byte(validator))
+	valid := subtle.ConstantTimeCompare(hash[:], hashedValidator) == 1
+	if valid && used && usedAt.Valid && time.Since(usedAt.Time) < m.Grace {
+		return userID, "", nil
+	}
+	if used || !valid {
+		if _, err := tx.Exec(`delete from remember_tokens where family = ?`, family); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return userID, "", models.ErrTokenReused
+	}
+
+	if _, err := tx.Exec(`update remember_tokens set used = true, used_at = UTC_TIMESTAMP() where id = ?`, id); err != nil {
+		return 0, "", err
+	}
+	newToken, err := m.insert(tx, userID, family, expires)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return userID, newToken, nil
+}
+
+// Revoke deletes the family token belongs to, e.g. on logout.
+func (m *RememberTokenModel) Revoke(token string) error {
+	selector := strings.SplitN(token, ":", 2)[0]
+	stmt := `delete t from remember_tokens t join remember_tokens s on s.family = t.family where s.selector = ?`
+	_, err := m.DB.Exec(stmt, selector)
+	return err
+}
+
+// RevokeAll deletes every token belonging to userID.
+func (m *RememberTokenModel) RevokeAll(userID int) error {
+	stmt := `delete from remember_tokens where user_id = ?`
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
+
+// DeleteExpired deletes expired tokens, and used tokens a day after they were
+// rotated. Until then a copied token is still caught when it's presented. It
+// returns how many tokens were deleted.
+func (m *RememberTokenModel) DeleteExpired() (int, error) {
+	stmt := `delete from remember_tokens where expires < UTC_TIMESTAMP() or (used and used_at < UTC_TIMESTAMP() - interval 1 day)`
+	result, err := m.DB.Exec(stmt)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (m *RememberTokenModel) insert(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, userID int, family string, expires time.Time) (string, error) {
+	selector, err := randomString(12)
+	if err != nil {
+		return "", err
+	}
+	validator, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(validator))
+
+	stmt := `insert into remember_tokens (selector, hashed_validator, user_id, family, used, created, expires) values (?, ?, ?, ?, false, UTC_TIMESTAMP(), ?)`
+	_, err = db.Exec(stmt, selector, hash[:], userID, family, expires.UTC())
+	if err != nil {
+		return "", err
+	}
+	return selector + ":" + validator, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_RememberTokenModelRotate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := RememberTokenModel{DB: db}
+	expires := time.Now().Add(time.Hour)
+
+	first, err := m.Issue(1, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, second, err := m.Rotate(first, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 1 {
+		t.Errorf("want user 1; got %d", userID)
+	}
+	if second == first {
+		t.Error("want a rotated token")
+	}
+
+	_, _, err = m.Rotate(first, expires)
+	if !errors.Is(err, models.ErrTokenReused) {
+		t.Errorf("want %v; got %v", models.ErrTokenReused, err)
+	}
+
+	_, _, err = m.Rotate(second, expires)
+	if !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("want the family to be revoked (%v); got %v", models.ErrInvalidToken, err)
+	}
+}
+
+func Test_RememberTokenModelGrace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := RememberTokenModel{DB: db, Grace: time.Minute}
+	expires := time.Now().Add(time.Hour)
+
+	first, err := m.Issue(1, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Rotate(first, expires); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, token, err := m.Rotate(first, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 1 || token != "" {
+		t.Errorf("want user 1 without a new token; got %d and %q", userID, token)
+	}
+
+	if _, err := db.Exec(`update remember_tokens set used_at = UTC_TIMESTAMP() - interval 2 day where used`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Issue(2, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want the used and the expired token deleted; got %d", n)
+	}
+}
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+func (m *ReportModel) Insert(snippetID, reporterID int, reason, details string) (int, error) {
+	stmt := `insert into reports (snippet_id, reporter_id, reason, details, created) values (?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, snippetID, reporterID, reason, details)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// CountSince returns how many reports reporterID has made since t.
+func (m *ReportModel) CountSince(reporterID int, t time.Time) (int, error) {
+	var n int
+	stmt := `select count(*) from reports where reporter_id = ? and created >= ?`
+	err := m.DB.QueryRow(stmt, reporterID, t.UTC()).Scan(&n)
+	return n, err
+}
+
+const reportColumns = `r.id, r.snippet_id, r.reporter_id, r.reason, r.details, r.status, r.created,
+	coalesce(r.resolved_by, 0), r.resolved, coalesce(s.title, ''), coalesce(s.user_id, 0), coalesce(u.name, '')`
+
+func scanReport(row scanner) (*models.Report, error) {
+	r := &models.Report{}
+	var resolved sql.NullTime
+	err := row.Scan(&r.ID, &r.SnippetID, &r.ReporterID, &r.Reason, &r.Details, &r.Status, &r.Created,
+		&r.ResolvedBy, &resolved, &r.SnippetTitle, &r.AuthorID, &r.Reporter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	r.Resolved = resolved.Time
+	return r, nil
+}
+
+const reportJoins = `reports r left join snippets s on s.id = r.snippet_id left join users u on u.id = r.reporter_id`
+
+func (m *ReportModel) Get(id int) (*models.Report, error) {
+	stmt := `select ` + reportColumns + ` from ` + reportJoins + ` where r.id = ?`
+	return scanReport(m.DB.QueryRow(stmt, id))
+}
+
+// Open returns the reports waiting for a moderator, oldest first.
+func (m *ReportModel) Open(limit, offset int) ([]*models.Report, error) {
+	stmt := `select ` + reportColumns + ` from ` + reportJoins + `
+	where r.status = ? order by r.created, r.id limit ? offset ?`
+	rows, err := m.DB.Query(stmt, models.ReportOpen, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []*models.Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// Resolve closes every open report on a snippet with status, and returns
+// them so that their reporters can be told.
+func (m *ReportModel) Resolve(snippetID int, status string, moderatorID int) ([]*models.Report, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `select ` + reportColumns + ` from ` + reportJoins + `
+	where r.snippet_id = ? and r.status = ? order by r.id for update`
+	rows, err := tx.Query(stmt, snippetID, models.ReportOpen)
+	if err != nil {
+		return nil, err
+	}
+	reports := []*models.Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt = `update reports set status = ?, resolved_by = ?, resolved = UTC_TIMESTAMP() where snippet_id = ? and status = ?`
+	if _, err := tx.Exec(stmt, status, moderatorID, snippetID, models.ReportOpen); err != nil {
+		return nil, err
+	}
+	for _, r := range reports {
+		r.Status = status
+		r.ResolvedBy = moderatorID
+		r.Resolved = time.Now()
+	}
+	return reports, tx.Commit()
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_ReportModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := ReportModel{DB: db}
+	snippets := SnippetModel{DB: db}
+	files := []*models.File{{Name: "a.txt", Content: "Buy now"}}
+
+	spamID, err := snippets.Insert(1, 0, "Spam", files, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := snippets.Insert(1, 0, "Other", files, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, snippetID := range []int{spamID, spamID, otherID} {
+		if _, err := m.Insert(snippetID, 1, models.ReasonSpam, "Ads"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := m.CountSince(1, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("want 3 recent reports; got %d", n)
+	}
+
+	open, err := m.Open(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 3 || open[0].SnippetTitle != "Spam" || open[0].Reporter != "Alice Jones" {
+		t.Fatalf("want 3 open reports with their snippet and reporter; got %v", open)
+	}
+
+	resolved, err := m.Resolve(spamID, models.ReportHidden, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 2 {
+		t.Errorf("want 2 resolved reports; got %d", len(resolved))
+	}
+	r, err := m.Get(resolved[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != models.ReportHidden || r.ResolvedBy != 2 || r.Resolved.IsZero() {
+		t.Errorf("want the report resolved by #2; got %+v", r)
+	}
+
+	open, err = m.Open(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 1 || open[0].SnippetID != otherID {
+		t.Errorf("want the other snippet's report to stay open; got %v", open)
+	}
+}
@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/session"
+)
+
+// SessionModel is a session.Store backed by the sessions table.
+type SessionModel struct {
+	DB *sql.DB
+}
+
+func (m *SessionModel) Find(id string) (*session.Record, error) {
+	stmt := `select id, coalesce(user_id, 0), data, user_agent, ip, created, last_seen, expiry from sessions where id = ? and expiry > UTC_TIMESTAMP()`
+	rec := &session.Record{}
+	err := m.DB.QueryRow(stmt, id).Scan(&rec.ID, &rec.UserID, &rec.Data, &rec.UserAgent, &rec.IP, &rec.Created, &rec.LastSeen, &rec.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+func (m *SessionModel) Save(rec *session.Record) error {
+	var userID sql.NullInt64
+	if rec.UserID > 0 {
+		userID = sql.NullInt64{Int64: int64(rec.UserID), Valid: true}
+	}
+	stmt := `insert into sessions (id, user_id, data, user_agent, ip, created, last_seen, expiry) values (?, ?, ?, ?, ?, ?, ?, ?)
+	on duplicate key update user_id = values(user_id), data = values(data), user_agent = values(user_agent), ip = values(ip), last_seen = values(last_seen), expiry = values(expiry)`
+	_, err := m.DB.Exec(stmt, rec.ID, userID, rec.Data, truncate(rec.UserAgent, 255), rec.IP, rec.Created, rec.LastSeen, rec.Expiry)
+	return err
+}
+
+func (m *SessionModel) Delete(id string) error {
+	stmt := `delete from sessions where id = ?`
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+func (m *SessionModel) List(userID int) ([]*session.Record, error) {
+	stmt := `select id, user_id, data, user_agent, ip, created, last_seen, expiry from sessions where user_id = ? and expiry > UTC_TIMESTAMP() order by last_seen desc`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []*session.Record{}
+	for rows.Next() {
+		rec := &session.Record{}
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Data, &rec.UserAgent, &rec.IP, &rec.Created, &rec.LastSeen, &rec.Expiry); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (m *SessionModel) DeleteByUser(userID int, exceptID string) error {
+	stmt := `delete from sessions where user_id = ? and id <> ?`
+	_, err := m.DB.Exec(stmt, userID, exceptID)
+	return err
+}
+
+func (m *SessionModel) DeleteExpired() error {
+	stmt := `delete from sessions where expiry <= UTC_TIMESTAMP()`
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
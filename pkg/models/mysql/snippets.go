@@ -2,18 +2,39 @@ package mysql
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"strings"
 
+	"github.com/aesuhaendi/go-snippetbox/pkg/envelope"
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
 )
 
+// SnippetModel stores snippets. If Keys is set, the content of files is
+// encrypted with a data key per snippet, which is stored wrapped by the
+// current master key. Without Keys, content is stored in plaintext and
+// encrypted content can't be read.
 type SnippetModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	Keys *envelope.Keyring
 }
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	stmt := `insert into snippets (title, content, created, expires) values (?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	result, err := m.DB.Exec(stmt, title, content, expires)
+// Insert adds a snippet with its files and tags. forkedFromID is zero unless
+// the snippet is a fork.
+func (m *SnippetModel) Insert(userID, forkedFromID int, title string, files []*models.File, tags []string, expires string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	key, wrapped, keyID, err := newDataKey(m.Keys)
+	if err != nil {
+		return 0, err
+	}
+	stmt := `insert into snippets (user_id, forked_from_id, title, created, expires, key_id, data_key)
+	values (?, nullif(?, 0), ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?)`
+	result, err := tx.Exec(stmt, userID, forkedFromID, title, expires, keyID, wrapped)
 	if err != nil {
 		return 0, err
 	}
@@ -21,13 +42,283 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return int(id), nil
+	if err := insertFiles(tx, int(id), files, key); err != nil {
+		return 0, err
+	}
+	if err := insertTags(tx, int(id), tags); err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `select id, title, content, created, expires from snippets where expires > UTC_TIMESTAMP() and id = ?`
+// Update replaces the title, files and tags of a snippet, keeping the title
+// and files it had as a revision. Revisions share the data key of their
+// snippet, so their files are copied as they are stored. Line comments on
+// files that changed become comments on the whole snippet.
+func (m *SnippetModel) Update(id int, title string, files []*models.File, tags []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keyID string
+	var wrapped []byte
+	stmt := `select key_id, data_key from snippets where id = ? for update`
+	if err := tx.QueryRow(stmt, id).Scan(&keyID, &wrapped); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+	var key []byte
+	if keyID == "" && m.Keys != nil {
+		// The snippet was stored before encryption was turned on.
+		if key, wrapped, keyID, err = m.Keys.NewDataKey(); err != nil {
+			return err
+		}
+		if err := encryptFiles(tx, id, key); err != nil {
+			return err
+		}
+	} else if key, err = unwrapDataKey(m.Keys, keyID, wrapped); err != nil {
+		return err
+	}
+
+	old, err := selectFiles(tx, id, key)
+	if err != nil {
+		return err
+	}
+
+	stmt = `insert into snippet_revisions (snippet_id, title, replaced) select id, title, UTC_TIMESTAMP() from snippets where id = ?`
+	result, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	stmt = `insert into snippet_revision_files (revision_id, position, name, content)
+	select ?, position, name, content from snippet_files where snippet_id = ?`
+	if _, err := tx.Exec(stmt, revisionID, id); err != nil {
+		return err
+	}
+
+	stmt = `update snippets set title = ?, key_id = ?, data_key = ? where id = ?`
+	if _, err := tx.Exec(stmt, title, keyID, wrapped, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippet_files where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if err := insertFiles(tx, id, files, key); err != nil {
+		return err
+	}
+	// Comments on lines of a file that changed may now point at the wrong
+	// lines, so they move to the comments on the whole snippet.
+	if err := detachComments(tx, id, changedFiles(old, files)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippet_tags where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if err := insertTags(tx, id, tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// selectFiles reads the files of a snippet in tx, decrypting them with key.
+func selectFiles(tx *sql.Tx, snippetID int, key []byte) ([]*models.File, error) {
+	stmt := `select name, content from snippet_files where snippet_id = ? order by position`
+	rows, err := tx.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var files []*models.File
+	for rows.Next() {
+		f := &models.File{}
+		if err := rows.Scan(&f.Name, &f.Content); err != nil {
+			return nil, err
+		}
+		if f.Content, err = decryptContent(key, f.Content); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// changedFiles returns the names of the files in old that are gone from
+// files, or whose content is different.
+func changedFiles(old, files []*models.File) []string {
+	content := map[string]string{}
+	for _, f := range files {
+		content[f.Name] = f.Content
+	}
+	var changed []string
+	for _, f := range old {
+		if c, ok := content[f.Name]; !ok || c != f.Content {
+			changed = append(changed, f.Name)
+		}
+	}
+	return changed
+}
+
+// insertFiles adds files to a snippet, encrypted with key unless it's nil.
+func insertFiles(tx *sql.Tx, snippetID int, files []*models.File, key []byte) error {
+	stmt := `insert into snippet_files (snippet_id, position, name, content) values (?, ?, ?, ?)`
+	for i, f := range files {
+		content, err := encryptContent(key, f.Content)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(stmt, snippetID, i, f.Name, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newDataKey returns a data key for the files of a snippet, with its wrapped
+// form and the ID of the master key that wrapped it. Without keys, files are
+// stored in plaintext, and the key and ID are empty.
+func newDataKey(keys *envelope.Keyring) (key, wrapped []byte, keyID string, err error) {
+	if keys == nil {
+		return nil, []byte{}, "", nil
+	}
+	return keys.NewDataKey()
+}
+
+// unwrapDataKey returns the data key of a snippet, or nil if its files are
+// stored in plaintext.
+func unwrapDataKey(keys *envelope.Keyring, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	if keys == nil {
+		return nil, models.ErrNoMasterKeys
+	}
+	return keys.Unwrap(keyID, wrapped)
+}
+
+// encryptContent seals the content of a file with key and encodes it as
+// base64, so that it fits the text column. A nil key leaves content alone.
+func encryptContent(key []byte, content string) (string, error) {
+	if key == nil {
+		return content, nil
+	}
+	ciphertext, err := envelope.Encrypt(key, []byte(content))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func decryptContent(key []byte, content string) (string, error) {
+	if key == nil {
+		return content, nil
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := envelope.Decrypt(key, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// insertTags adds tags to a snippet, creating the tags that don't exist yet.
+func insertTags(tx *sql.Tx, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.Exec(`insert ignore into tags (name) values (?)`, tag); err != nil {
+			return err
+		}
+		stmt := `insert ignore into snippet_tags (snippet_id, tag_id) select ?, id from tags where name = ?`
+		if _, err := tx.Exec(stmt, snippetID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFiles fills in the files and tags of snippets, decrypting the files
+// with keys.
+func loadFiles(db *sql.DB, keys *envelope.Keyring, snippets ...*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+	byID := map[int]*models.Snippet{}
+	args := []interface{}{}
+	for _, s := range snippets {
+		byID[s.ID] = s
+		args = append(args, s.ID)
+	}
+	in := `(?` + strings.Repeat(", ?", len(args)-1) + `)`
+
+	stmt := `select f.snippet_id, f.name, f.content, s.key_id, s.data_key from snippet_files f
+	join snippets s on s.id = f.snippet_id
+	where f.snippet_id in ` + in + ` order by f.snippet_id, f.position`
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	dataKeys := map[int][]byte{}
+	for rows.Next() {
+		var id int
+		var keyID string
+		var wrapped []byte
+		f := &models.File{}
+		if err := rows.Scan(&id, &f.Name, &f.Content, &keyID, &wrapped); err != nil {
+			return err
+		}
+		key, ok := dataKeys[id]
+		if !ok {
+			if key, err = unwrapDataKey(keys, keyID, wrapped); err != nil {
+				return err
+			}
+			dataKeys[id] = key
+		}
+		if f.Content, err = decryptContent(key, f.Content); err != nil {
+			return err
+		}
+		byID[id].Files = append(byID[id].Files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt = `select st.snippet_id, t.name from snippet_tags st join tags t on t.id = st.tag_id
+	where st.snippet_id in ` + in + ` order by st.snippet_id, t.name`
+	tagRows, err := db.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var id int
+		var tag string
+		if err := tagRows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+	return tagRows.Err()
+}
+
+// snippetColumns are the columns scanSnippet expects, in order. They are
+// qualified so that they can be used in joins.
+const snippetColumns = `snippets.id, snippets.title, snippets.created, snippets.expires, snippets.hidden, coalesce(snippets.user_id, 0),
+	(select count(*) from snippet_stars st where st.snippet_id = snippets.id),
+	coalesce(snippets.forked_from_id, 0), (select count(*) from snippets f where f.forked_from_id = snippets.id)`
+
+func scanSnippet(row scanner) (*models.Snippet, error) {
 	s := &models.Snippet{}
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Title, &s.Created, &s.Expires, &s.Hidden, &s.UserID, &s.Stars, &s.ForkedFromID, &s.Forks)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -38,17 +329,17 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `select id, title, content, created, expires from snippets where expires > UTC_TIMESTAMP() order by created desc limit 10`
-	rows, err := m.DB.Query(stmt)
+// querySnippets runs a select of snippetColumns and scans every row.
+func querySnippets(db *sql.DB, stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := db.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	snippets := []*models.Snippet{}
 	for rows.Next() {
-		s := &models.Snippet{}
-		if err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires); err != nil {
+		s, err := scanSnippet(rows)
+		if err != nil {
 			return nil, err
 		}
 		snippets = append(snippets, s)
@@ -58,3 +349,271 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	}
 	return snippets, nil
 }
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where expires > UTC_TIMESTAMP() and id = ?`
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		return nil, err
+	}
+	return s, loadFiles(m.DB, m.Keys, s)
+}
+
+// GetAny returns a snippet with its files even if it has expired, for
+// moderators.
+func (m *SnippetModel) GetAny(id int) (*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where id = ?`
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		return nil, err
+	}
+	return s, loadFiles(m.DB, m.Keys, s)
+}
+
+// Revisions returns the earlier versions of a snippet, oldest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	var keyID string
+	var wrapped []byte
+	stmt := `select key_id, data_key from snippets where id = ?`
+	if err := m.DB.QueryRow(stmt, snippetID).Scan(&keyID, &wrapped); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	key, err := unwrapDataKey(m.Keys, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `select r.id, r.title, r.replaced, f.name, f.content from snippet_revisions r
+	join snippet_revision_files f on f.revision_id = r.id
+	where r.snippet_id = ? order by r.id, f.position`
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev := &models.Revision{}
+		f := &models.File{}
+		if err := rows.Scan(&rev.ID, &rev.Title, &rev.Replaced, &f.Name, &f.Content); err != nil {
+			return nil, err
+		}
+		if f.Content, err = decryptContent(key, f.Content); err != nil {
+			return nil, err
+		}
+		if n := len(revisions); n > 0 && revisions[n-1].ID == rev.ID {
+			rev = revisions[n-1]
+		} else {
+			revisions = append(revisions, rev)
+		}
+		rev.Files = append(rev.Files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where expires > UTC_TIMESTAMP() and not hidden order by created desc limit 10`
+	return querySnippets(m.DB, stmt)
+}
+
+// Search returns snippets whose title contains query, including expired and
+// hidden ones, newest first.
+func (m *SnippetModel) Search(query string, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where title like ? order by created desc limit ? offset ?`
+	return querySnippets(m.DB, stmt, "%"+escapeLike(query)+"%", limit, offset)
+}
+
+// ByUser returns every snippet owned by userID with its files, including
+// expired and hidden ones, oldest first.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where user_id = ? order by id`
+	snippets, err := querySnippets(m.DB, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	return snippets, loadFiles(m.DB, m.Keys, snippets...)
+}
+
+// PublicByUser returns the snippets of userID that anyone can see, newest
+// first.
+func (m *SnippetModel) PublicByUser(userID, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets where user_id = ? and expires > UTC_TIMESTAMP() and not hidden order by id desc limit ? offset ?`
+	return querySnippets(m.DB, stmt, userID, limit, offset)
+}
+
+func (m *SnippetModel) Count() (*models.SnippetCounts, error) {
+	c := &models.SnippetCounts{}
+	stmt := `select count(*), coalesce(sum(expires > UTC_TIMESTAMP() and not hidden), 0), coalesce(sum(hidden), 0) from snippets`
+	if err := m.DB.QueryRow(stmt).Scan(&c.Total, &c.Live, &c.Hidden); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *SnippetModel) SetHidden(id int, hidden bool) error {
+	stmt := `update snippets set hidden = ? where id = ?`
+	_, err := m.DB.Exec(stmt, hidden, id)
+	return err
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from snippet_files where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	stmt := `delete f from snippet_revision_files f join snippet_revisions r on r.id = f.revision_id where r.snippet_id = ?`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippet_revisions where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippet_tags where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from collection_snippets where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from reports where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from comments where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippet_stars where snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from snippets where id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RotateKeys re-wraps the data keys of up to batch snippets that aren't
+// wrapped by the current master key, and encrypts the files of snippets
+// stored in plaintext. It returns how many snippets it changed, so it can be
+// called until it returns zero.
+func (m *SnippetModel) RotateKeys(batch int) (int, error) {
+	if m.Keys == nil {
+		return 0, models.ErrNoMasterKeys
+	}
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type dataKey struct {
+		snippetID int
+		keyID     string
+		wrapped   []byte
+	}
+	stmt := `select id, key_id, data_key from snippets where key_id <> ? order by id limit ? for update`
+	rows, err := tx.Query(stmt, m.Keys.CurrentID(), batch)
+	if err != nil {
+		return 0, err
+	}
+	var stale []*dataKey
+	for rows.Next() {
+		k := &dataKey{}
+		if err := rows.Scan(&k.snippetID, &k.keyID, &k.wrapped); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, k := range stale {
+		var wrapped []byte
+		var keyID string
+		if k.keyID == "" {
+			var key []byte
+			if key, wrapped, keyID, err = m.Keys.NewDataKey(); err != nil {
+				return 0, err
+			}
+			if err := encryptFiles(tx, k.snippetID, key); err != nil {
+				return 0, err
+			}
+		} else {
+			key, err := m.Keys.Unwrap(k.keyID, k.wrapped)
+			if err != nil {
+				return 0, err
+			}
+			if wrapped, keyID, err = m.Keys.Wrap(key); err != nil {
+				return 0, err
+			}
+		}
+		stmt := `update snippets set key_id = ?, data_key = ? where id = ?`
+		if _, err := tx.Exec(stmt, keyID, wrapped, k.snippetID); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), tx.Commit()
+}
+
+// encryptFiles encrypts the plaintext files of a snippet and of its
+// revisions with key.
+func encryptFiles(tx *sql.Tx, snippetID int, key []byte) error {
+	err := encryptContents(tx, key,
+		`select snippet_id, position, content from snippet_files where snippet_id = ? for update`,
+		`update snippet_files set content = ? where snippet_id = ? and position = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+	return encryptContents(tx, key,
+		`select f.revision_id, f.position, f.content from snippet_revision_files f
+		join snippet_revisions r on r.id = f.revision_id where r.snippet_id = ? for update`,
+		`update snippet_revision_files set content = ? where revision_id = ? and position = ?`, snippetID)
+}
+
+// encryptContents encrypts the files selected by query as rows of owner ID,
+// position and content, and saves them with update.
+func encryptContents(tx *sql.Tx, key []byte, query, update string, args ...interface{}) error {
+	type file struct {
+		ownerID, position int
+		content           string
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	var files []*file
+	for rows.Next() {
+		f := &file{}
+		if err := rows.Scan(&f.ownerID, &f.position, &f.content); err != nil {
+			rows.Close()
+			return err
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		encrypted, err := encryptContent(key, f.content)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(update, encrypted, f.ownerID, f.position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
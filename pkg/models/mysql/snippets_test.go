@@ -0,0 +1,262 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/envelope"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_SnippetModelFork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+	parentID, err := m.Insert(1, 0, "Original", []*models.File{{Name: "a.txt", Content: "Content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkID, err := m.Insert(1, parentID, "Original", []*models.File{{Name: "a.txt", Content: "Changed content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fork, err := m.Get(forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ForkedFromID != parentID {
+		t.Errorf("want forked from %d; got %d", parentID, fork.ForkedFromID)
+	}
+	parent, err := m.Get(parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.ForkedFromID != 0 || parent.Forks != 1 {
+		t.Errorf("want an original with 1 fork; got forked from %d with %d forks", parent.ForkedFromID, parent.Forks)
+	}
+}
+
+func Test_SnippetModelFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+	id, err := m.Insert(1, 0, "Project", []*models.File{
+		{Name: "main.go", Content: "package main"},
+		{Name: "go.mod", Content: "module example.com/project"},
+	}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 2 || s.Files[0].Name != "main.go" || s.Files[1].Name != "go.mod" {
+		t.Fatalf("want main.go and go.mod; got %v", s.Files)
+	}
+
+	err = m.Update(id, "Renamed", []*models.File{{Name: "go.mod", Content: "module example.com/renamed"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Renamed" || len(s.Files) != 1 || s.Files[0].Content != "module example.com/renamed" {
+		t.Errorf("want the title and files replaced; got %q with %v", s.Title, s.Files)
+	}
+
+	revisions, err := m.Revisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Title != "Project" || len(revisions[0].Files) != 2 || revisions[0].Files[0].Content != "package main" {
+		t.Errorf("want the old version kept as a revision; got %v", revisions)
+	}
+
+	mine, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 1 || len(mine[0].Files) != 1 {
+		t.Errorf("want 1 snippet with its file; got %v", mine)
+	}
+}
+
+func Test_SnippetModelEncryption(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	oldKey, newKey := bytes.Repeat([]byte{1}, envelope.KeySize), bytes.Repeat([]byte{2}, envelope.KeySize)
+	oldKeys, err := envelope.NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKeys, err := envelope.NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := SnippetModel{DB: db}
+	plainID, err := plain.Insert(1, 0, "Plain", []*models.File{{Name: "a.txt", Content: "Not a secret"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := SnippetModel{DB: db, Keys: oldKeys}
+	id, err := m.Insert(1, 0, "Secret", []*models.File{{Name: "a.txt", Content: "A secret"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := db.QueryRow(`select content from snippet_files where snippet_id = ?`, id).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "secret") {
+		t.Errorf("want encrypted content; got %q", stored)
+	}
+	if _, err := plain.Get(id); !errors.Is(err, models.ErrNoMasterKeys) {
+		t.Errorf("want %v; got %v", models.ErrNoMasterKeys, err)
+	}
+
+	m.Keys = newKeys
+	total := 0
+	for {
+		n, err := m.RotateKeys(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	if total != 2 {
+		t.Errorf("want 2 snippets rotated; got %d", total)
+	}
+
+	m.Keys, err = envelope.NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		id   int
+		want string
+	}{{plainID, "Not a secret"}, {id, "A secret"}} {
+		s, err := m.Get(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Files[0].Content != tt.want {
+			t.Errorf("want %q; got %q", tt.want, s.Files[0].Content)
+		}
+	}
+}
+
+func Test_SnippetModelDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+	comments := CommentModel{DB: db}
+	stars := StarModel{DB: db}
+	id, err := m.Insert(1, 0, "Doomed", []*models.File{{Name: "a.txt", Content: "Content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commentID, err := comments.Insert(id, 1, 0, "", 0, 0, "Nice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stars.Toggle(2, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.Get(commentID); err != models.ErrNoRecord {
+		t.Errorf("want the comments deleted; got %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`select count(*) from snippet_stars where snippet_id = ?`, id).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want the stars deleted; got %d", n)
+	}
+}
+
+func Test_SnippetModelGetAny(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+	id, err := m.Insert(1, 0, "Expired", []*models.File{{Name: "a.txt", Content: "Gone"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`update snippets set expires = UTC_TIMESTAMP() - interval 1 day where id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Get(id); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+	s, err := m.GetAny(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Expired" || len(s.Files) != 1 {
+		t.Errorf("want the expired snippet with its file; got %q with %v", s.Title, s.Files)
+	}
+	if _, err := m.GetAny(id + 1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func Test_changedFiles(t *testing.T) {
+	old := []*models.File{
+		{Name: "same.txt", Content: "Same"},
+		{Name: "edited.txt", Content: "Before"},
+		{Name: "removed.txt", Content: "Gone"},
+	}
+	files := []*models.File{
+		{Name: "edited.txt", Content: "After"},
+		{Name: "same.txt", Content: "Same"},
+		{Name: "added.txt", Content: "New"},
+	}
+	got := changedFiles(old, files)
+	want := []string{"edited.txt", "removed.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type StarModel struct {
+	DB *sql.DB
+}
+
+// Toggle stars the snippet for the user, or unstars it if they had already
+// starred it. It reports whether the snippet is now starred.
+func (m *StarModel) Toggle(userID, snippetID int) (bool, error) {
+	result, err := m.DB.Exec(`delete from snippet_stars where user_id = ? and snippet_id = ?`, userID, snippetID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, nil
+	}
+	stmt := `insert ignore into snippet_stars (user_id, snippet_id, created) values (?, ?, UTC_TIMESTAMP())`
+	if _, err := m.DB.Exec(stmt, userID, snippetID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	var starred bool
+	stmt := `select exists(select 1 from snippet_stars where user_id = ? and snippet_id = ?)`
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// ByUser returns the live snippets the user has starred, most recently
+// starred first.
+func (m *StarModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets join snippet_stars s on s.snippet_id = snippets.id
+	where s.user_id = ? and snippets.expires > UTC_TIMESTAMP() and not snippets.hidden order by s.created desc`
+	return querySnippets(m.DB, stmt, userID)
+}
+
+// MostStarred returns the live snippets that got the most stars since the
+// given time.
+func (m *StarModel) MostStarred(since time.Time, limit int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets
+	join (select snippet_id, count(*) as n from snippet_stars where created >= ? group by snippet_id) recent on recent.snippet_id = snippets.id
+	where snippets.expires > UTC_TIMESTAMP() and not snippets.hidden order by recent.n desc, snippets.id desc limit ?`
+	return querySnippets(m.DB, stmt, since.UTC(), limit)
+}
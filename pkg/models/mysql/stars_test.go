@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_StarModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := StarModel{db}
+	snippets := SnippetModel{DB: db}
+
+	id, err := snippets.Insert(1, 0, "Starred", []*models.File{{Name: "a.txt", Content: "Content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starred, err := m.Toggle(1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !starred {
+		t.Error("want the first toggle to star")
+	}
+
+	s, err := snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Stars != 1 {
+		t.Errorf("want 1 star; got %d", s.Stars)
+	}
+
+	popular, err := m.MostStarred(time.Now().Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(popular) != 1 || popular[0].ID != id {
+		t.Errorf("want snippet %d to be the most starred; got %v", id, popular)
+	}
+
+	starred, err = m.Toggle(1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starred {
+		t.Error("want the second toggle to unstar")
+	}
+	mine, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 0 {
+		t.Errorf("want no stars; got %d", len(mine))
+	}
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Snippets returns the live snippets with a tag, newest first.
+func (m *TagModel) Snippets(tag string, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `select ` + snippetColumns + ` from snippets
+	join snippet_tags st on st.snippet_id = snippets.id join tags t on t.id = st.tag_id
+	where t.name = ? and snippets.expires > UTC_TIMESTAMP() and not snippets.hidden
+	order by snippets.id desc limit ? offset ?`
+	return querySnippets(m.DB, stmt, tag, limit, offset)
+}
+
+// Popular returns the tags on the most live snippets, by name.
+func (m *TagModel) Popular(limit int) ([]*models.Tag, error) {
+	stmt := `select name, n from (
+		select t.name, count(*) as n from tags t
+		join snippet_tags st on st.tag_id = t.id join snippets s on s.id = st.snippet_id
+		where s.expires > UTC_TIMESTAMP() and not s.hidden
+		group by t.id, t.name order by n desc, t.name limit ?
+	) popular order by name`
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
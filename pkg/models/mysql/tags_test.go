@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_TagModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := TagModel{db}
+	snippets := SnippetModel{DB: db}
+	files := []*models.File{{Name: "a.txt", Content: "Content"}}
+
+	goID, err := snippets.Insert(1, 0, "Go", files, []string{"go", "http"}, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snippets.Insert(1, 0, "SQL", files, []string{"sql", "go"}, "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(goID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"go", "http"}; !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("want tags %q; got %q", want, s.Tags)
+	}
+
+	tagged, err := m.Snippets("http", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != goID {
+		t.Errorf("want snippet %d; got %v", goID, tagged)
+	}
+
+	popular, err := m.Popular(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(popular) != 2 || popular[0].Name != "go" || popular[0].Count != 2 {
+		t.Errorf("want go on 2 snippets first; got %v", popular)
+	}
+
+	if err := snippets.Update(goID, "Go", files, []string{"web"}); err != nil {
+		t.Fatal(err)
+	}
+	tagged, err = m.Snippets("http", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 0 {
+		t.Errorf("want no snippets tagged http; got %d", len(tagged))
+	}
+}
@@ -1,27 +1,226 @@
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   title VARCHAR(100) NOT NULL,
-  content TEXT NOT NULL,
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  expires DATETIME NOT NULL,
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  user_id INTEGER,
+  forked_from_id INTEGER,
+  key_id VARCHAR(16) NOT NULL DEFAULT '',
+  data_key VARBINARY(255) NOT NULL DEFAULT ''
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_forked_from_id ON snippets(forked_from_id);
+
+CREATE TABLE snippet_files (
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content MEDIUMTEXT NOT NULL,
+  PRIMARY KEY (snippet_id, position),
+  CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name)
+);
+
+CREATE TABLE snippet_revisions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  replaced DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions(snippet_id);
+
+CREATE TABLE snippet_revision_files (
+  revision_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content MEDIUMTEXT NOT NULL,
+  PRIMARY KEY (revision_id, position)
+);
 
 CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   name VARCHAR(255) NOT NULL,
+  username VARCHAR(30),
   email VARCHAR(255) NOT NULL,
   hashed_password CHAR(60) NOT NULL,
   created DATETIME NOT NULL,
-  active BOOLEAN NOT NULL DEFAULT TRUE
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+  self_deactivated BOOLEAN NOT NULL DEFAULT FALSE,
+  delete_after DATETIME,
+  delete_snippets BOOLEAN NOT NULL DEFAULT FALSE,
+  profile_public BOOLEAN NOT NULL DEFAULT TRUE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+ALTER TABLE users ADD CONSTRAINT users_uc_username UNIQUE (username);
 
-INSERT INTO users (name, email, hashed_password, created) VALUES (
+INSERT INTO users (name, username, email, hashed_password, created) VALUES (
   'Alice Jones',
+  'alice',
   'alice@example.com',
   '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
   '2018-12-23 17:25:22'
-);
\ No newline at end of file
+);
+CREATE TABLE remember_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  selector CHAR(16) NOT NULL,
+  hashed_validator BINARY(32) NOT NULL,
+  user_id INTEGER NOT NULL,
+  family CHAR(16) NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE,
+  used_at DATETIME,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL
+);
+
+ALTER TABLE remember_tokens ADD CONSTRAINT remember_tokens_uc_selector UNIQUE (selector);
+
+CREATE TABLE audit_events (
+  id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  created DATETIME(6) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  actor_id INTEGER,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  details JSON
+);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TABLE user_identities (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  provider VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL
+);
+
+CREATE TABLE snippet_stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE TABLE tags (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(30) NOT NULL,
+  CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+  snippet_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE TABLE collections (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  slug VARCHAR(50) NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  description TEXT NOT NULL,
+  visibility VARCHAR(20) NOT NULL DEFAULT 'private',
+  created DATETIME NOT NULL,
+  CONSTRAINT collections_uc_slug UNIQUE (slug)
+);
+
+CREATE TABLE collection_snippets (
+  collection_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  file VARCHAR(255) NOT NULL DEFAULT '',
+  line_start INTEGER,
+  line_end INTEGER,
+  body TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  edited DATETIME,
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  deleted BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id, created);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+
+CREATE TABLE reports (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  reporter_id INTEGER NOT NULL,
+  reason VARCHAR(20) NOT NULL,
+  details VARCHAR(500) NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL DEFAULT 'open',
+  created DATETIME NOT NULL,
+  resolved_by INTEGER,
+  resolved DATETIME
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created);
+CREATE INDEX idx_reports_snippet_id ON reports(snippet_id);
+CREATE INDEX idx_reports_reporter_id ON reports(reporter_id, created);
+
+CREATE TABLE rate_limits (
+  bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+  tokens DOUBLE NOT NULL,
+  updated DATETIME(6),
+  expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_expires ON rate_limits(expires);
+
+CREATE TABLE exports (
+  name CHAR(36) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  ready BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_exports_user_id ON exports(user_id);
+
+CREATE TABLE notifications (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  message VARCHAR(255) NOT NULL,
+  link VARCHAR(1024) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL,
+  is_read BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created);
+
+CREATE TABLE sessions (
+  id CHAR(64) NOT NULL PRIMARY KEY,
+  user_id INTEGER,
+  data BLOB NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expiry ON sessions(expiry);
+
+CREATE TABLE antispam_tokens (
+  token_hash BINARY(32) NOT NULL PRIMARY KEY,
+  expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_antispam_tokens_expires ON antispam_tokens(expires);
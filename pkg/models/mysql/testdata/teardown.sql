@@ -1,3 +1,39 @@
+DROP TABLE antispam_tokens;
+
+DROP TABLE sessions;
+
+DROP TABLE notifications;
+
+DROP TABLE exports;
+
+DROP TABLE rate_limits;
+
+DROP TABLE reports;
+
+DROP TABLE comments;
+
+DROP TABLE snippet_stars;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE collection_snippets;
+
+DROP TABLE collections;
+
+DROP TABLE tags;
+
+DROP TABLE user_identities;
+
+DROP TABLE audit_events;
+
+DROP TABLE remember_tokens;
+
 DROP TABLE users;
 
+DROP TABLE snippet_revision_files;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippet_files;
+
 DROP TABLE snippets;
\ No newline at end of file
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/aesuhaendi/go-snippetbox/pkg/models"
 
@@ -15,33 +16,53 @@ type UserModel struct {
 	DB *sql.DB
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, username, email, password string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
-	stmt := `insert into users (name, email, hashed_password, created) values (?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `insert into users (name, username, email, hashed_password, created) values (?, ?, ?, ?, UTC_TIMESTAMP())`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.Exec(stmt, name, username, email, string(hashedPassword))
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return models.ErrDuplicateEmail
-			}
+		if isDuplicateEmail(err) {
+			return models.ErrDuplicateEmail
+		}
+		if isDuplicateUsername(err) {
+			return models.ErrDuplicateUsername
 		}
 		return err
 	}
 	return nil
 }
 
+func isDuplicateEmail(err error) bool {
+	return isDuplicate(err, "users_uc_email")
+}
+
+func isDuplicateUsername(err error) bool {
+	return isDuplicate(err, "users_uc_username")
+}
+
+func isDuplicate(err error, constraint string) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+	}
+	return false
+}
+
+// Authenticate checks the user's password. Users who deactivated their own
+// account may still log in, which reactivates it and cancels any pending
+// deletion; users deactivated by an admin may not.
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
 	var hasedPassword []byte
-	stmt := `select id, hashed_password from users where email = ? and active = true`
+	var active bool
+	stmt := `select id, hashed_password, active from users where email = ? and (active = true or self_deactivated = true)`
 	row := m.DB.QueryRow(stmt, email)
-	err := row.Scan(&id, &hasedPassword)
+	err := row.Scan(&id, &hasedPassword, &active)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, models.ErrInvalidCredentials
@@ -57,13 +78,24 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 			return 0, err
 		}
 	}
+	if !active {
+		stmt = `update users set active = true, self_deactivated = false, delete_after = null where id = ?`
+		if _, err := m.DB.Exec(stmt, id); err != nil {
+			return 0, err
+		}
+	}
 	return id, nil
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
+const userColumns = `id, name, coalesce(username, ''), email, created, active, role, password_reset_required, profile_public`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*models.User, error) {
 	u := &models.User{}
-	stmt := `select id, name, email, created, active from users where id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	err := row.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.Active, &u.Role, &u.PasswordResetRequired, &u.ProfilePublic)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -74,6 +106,11 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	return u, nil
 }
 
+func (m *UserModel) Get(id int) (*models.User, error) {
+	stmt := `select ` + userColumns + ` from users where id = ?`
+	return scanUser(m.DB.QueryRow(stmt, id))
+}
+
 func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
 	u := &models.User{}
 	stmt := `select hashed_password from users where id = ?`
@@ -99,7 +136,7 @@ func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string)
 	if err != nil {
 		return err
 	}
-	stmt = `update users set hashed_password = ? where id = ?`
+	stmt = `update users set hashed_password = ?, password_reset_required = false where id = ?`
 	_, err = m.DB.Exec(stmt, hashedNewPassword, id)
 	if err != nil {
 		return err
@@ -107,3 +144,186 @@ func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string)
 
 	return nil
 }
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	stmt := `select ` + userColumns + ` from users where email = ?`
+	return scanUser(m.DB.QueryRow(stmt, email))
+}
+
+func (m *UserModel) GetByUsername(username string) (*models.User, error) {
+	stmt := `select ` + userColumns + ` from users where username = ?`
+	return scanUser(m.DB.QueryRow(stmt, username))
+}
+
+func (m *UserModel) UpdateProfile(id int, username string, public bool) error {
+	stmt := `update users set username = ?, profile_public = ? where id = ?`
+	_, err := m.DB.Exec(stmt, username, public, id)
+	if isDuplicateUsername(err) {
+		return models.ErrDuplicateUsername
+	}
+	return err
+}
+
+// Search returns users whose name, username or email contains query, or every user if
+// query is empty.
+func (m *UserModel) Search(query string, limit, offset int) ([]*models.User, error) {
+	stmt := `select ` + userColumns + ` from users where name like ? or email like ? or username like ? order by id limit ? offset ?`
+	pattern := "%" + escapeLike(query) + "%"
+	rows, err := m.DB.Query(stmt, pattern, pattern, pattern, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []*models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (m *UserModel) Count() (*models.UserCounts, error) {
+	c := &models.UserCounts{}
+	stmt := `select count(*), coalesce(sum(active), 0) from users`
+	if err := m.DB.QueryRow(stmt).Scan(&c.Total, &c.Active); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetActive is used by admins. A user they deactivate can't reactivate
+// themselves by logging in. Reactivating a user also cancels a deletion they
+// scheduled, so that deactivating them again later doesn't purge the account.
+func (m *UserModel) SetActive(id int, active bool) error {
+	stmt := `update users set active = ?, self_deactivated = false where id = ?`
+	if active {
+		stmt = `update users set active = ?, self_deactivated = false, delete_after = null, delete_snippets = false where id = ?`
+	}
+	_, err := m.DB.Exec(stmt, active, id)
+	return err
+}
+
+// RequirePasswordReset makes the user change their password the next time
+// they log in.
+func (m *UserModel) RequirePasswordReset(id int) error {
+	stmt := `update users set password_reset_required = true where id = ?`
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	if !models.ValidRole(role) {
+		return models.ErrInvalidRole
+	}
+	stmt := `update users set role = ? where id = ?`
+	_, err := m.DB.Exec(stmt, role, id)
+	return err
+}
+
+// Deactivate is used by users on their own account. Logging in again
+// reactivates it.
+func (m *UserModel) Deactivate(id int) error {
+	stmt := `update users set active = false, self_deactivated = true where id = ?`
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// ScheduleDeletion deactivates the account and marks it to be purged after
+// the given time, unless the user logs in again before then. Their snippets
+// are deleted with it if deleteSnippets is set, otherwise they are kept
+// without an owner.
+func (m *UserModel) ScheduleDeletion(id int, after time.Time, deleteSnippets bool) error {
+	stmt := `update users set active = false, self_deactivated = true, delete_after = ?, delete_snippets = ? where id = ?`
+	_, err := m.DB.Exec(stmt, after.UTC(), deleteSnippets, id)
+	return err
+}
+
+// PurgeDeleted permanently deletes the accounts whose deletion is due and
+// returns how many there were.
+func (m *UserModel) PurgeDeleted() (int, error) {
+	stmt := `select id, delete_snippets from users where delete_after <= UTC_TIMESTAMP() and active = false`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		id             int
+		deleteSnippets bool
+	}
+	users := []due{}
+	for rows.Next() {
+		var u due
+		if err := rows.Scan(&u.id, &u.deleteSnippets); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, u := range users {
+		if err := m.purge(u.id, u.deleteSnippets); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+func (m *UserModel) purge(id int, deleteSnippets bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	snippets := []string{`update snippets set user_id = null where user_id = ?`}
+	if deleteSnippets {
+		snippets = []string{
+			`delete f from snippet_files f join snippets s on s.id = f.snippet_id where s.user_id = ?`,
+			`delete f from snippet_revision_files f join snippet_revisions r on r.id = f.revision_id join snippets s on s.id = r.snippet_id where s.user_id = ?`,
+			`delete r from snippet_revisions r join snippets s on s.id = r.snippet_id where s.user_id = ?`,
+			`delete t from snippet_tags t join snippets s on s.id = t.snippet_id where s.user_id = ?`,
+			`delete cs from collection_snippets cs join snippets s on s.id = cs.snippet_id where s.user_id = ?`,
+			`delete r from reports r join snippets s on s.id = r.snippet_id where s.user_id = ?`,
+			`delete c from comments c join snippets s on s.id = c.snippet_id where s.user_id = ?`,
+			`delete st from snippet_stars st join snippets s on s.id = st.snippet_id where s.user_id = ?`,
+			`delete from snippets where user_id = ?`,
+		}
+	}
+	for _, stmt := range append(snippets,
+		`delete from user_identities where user_id = ?`,
+		`delete from remember_tokens where user_id = ?`,
+		`delete from sessions where user_id = ?`,
+		`delete from notifications where user_id = ?`,
+		`delete from exports where user_id = ?`,
+		`delete from snippet_stars where user_id = ?`,
+		`delete from reports where reporter_id = ?`,
+		`delete cs from collection_snippets cs join collections c on c.id = cs.collection_id where c.user_id = ?`,
+		`delete from collections where user_id = ?`,
+		// Threads the user started that others replied to are kept, without
+		// their first comment, as Tombstone keeps them.
+		`delete from comments where user_id = ? and parent_id is not null`,
+		`update comments c join comments r on r.parent_id = c.id set c.body = '', c.deleted = true where c.user_id = ?`,
+		`delete c from comments c left join comments r on r.parent_id = c.id where c.user_id = ? and r.id is null`,
+		`delete from users where id = ?`,
+	) {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -23,11 +23,14 @@ func Test_UserModelGet(t *testing.T) {
 			name:   "Valid ID",
 			userID: 1,
 			wantUser: &models.User{
-				ID:      1,
-				Name:    "Alice Jones",
-				Email:   "alice@example.com",
-				Created: time.Date(2018, 12, 23, 17, 25, 22, 0, time.UTC),
-				Active:  true,
+				ID:            1,
+				Name:          "Alice Jones",
+				Username:      "alice",
+				Email:         "alice@example.com",
+				Created:       time.Date(2018, 12, 23, 17, 25, 22, 0, time.UTC),
+				Active:        true,
+				Role:          models.RoleUser,
+				ProfilePublic: true,
 			},
 			wantError: nil,
 		},
@@ -62,3 +65,157 @@ func Test_UserModelGet(t *testing.T) {
 		})
 	}
 }
+
+func Test_UserModelPurgeDeleted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	users := UserModel{db}
+	snippets := SnippetModel{DB: db}
+
+	id, err := snippets.Insert(1, 0, "Mine", []*models.File{{Name: "a.txt", Content: "Content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = users.ScheduleDeletion(1, time.Now().Add(time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := users.PurgeDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want nothing purged during the grace period; got %d", n)
+	}
+
+	if err := (&NotificationModel{DB: db}).Insert(1, "Your export is ready", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err = users.ScheduleDeletion(1, time.Now().Add(-time.Minute), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = users.PurgeDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1; got %d", n)
+	}
+
+	_, err = users.Get(1)
+	if err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+	s, err := snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UserID != 0 {
+		t.Errorf("want the snippet to be anonymised; got owner %d", s.UserID)
+	}
+	var notifications int
+	if err := db.QueryRow(`select count(*) from notifications where user_id = 1`).Scan(&notifications); err != nil {
+		t.Fatal(err)
+	}
+	if notifications != 0 {
+		t.Errorf("want the notifications deleted; got %d", notifications)
+	}
+}
+
+func Test_UserModelPurgeComments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	users := UserModel{db}
+	comments := CommentModel{db}
+	if err := users.Insert("Bob", "bob", "bob@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	var bobID int
+	if err := db.QueryRow(`select id from users where username = 'bob'`).Scan(&bobID); err != nil {
+		t.Fatal(err)
+	}
+	snippetID, err := (&SnippetModel{DB: db}).Insert(bobID, 0, "Bob's", []*models.File{{Name: "a.txt", Content: "Content"}}, nil, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threadID, err := comments.Insert(snippetID, 1, 0, "", 0, 0, "Answered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyID, err := comments.Insert(snippetID, bobID, threadID, "", 0, 0, "Thanks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.Insert(snippetID, 1, threadID, "", 0, 0, "You're welcome"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.Insert(snippetID, 1, 0, "", 0, 0, "Unanswered"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.ScheduleDeletion(1, time.Now().Add(-time.Minute), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.PurgeDeleted(); err != nil {
+		t.Fatal(err)
+	}
+
+	threads, err := comments.BySnippet(snippetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || threads[0].ID != threadID {
+		t.Fatalf("want only the answered thread; got %v", threads)
+	}
+	if !threads[0].Deleted || threads[0].Body != "" {
+		t.Errorf("want the first comment cleared; got %+v", threads[0])
+	}
+	if len(threads[0].Replies) != 1 || threads[0].Replies[0].ID != replyID {
+		t.Errorf("want only Bob's reply kept; got %v", threads[0].Replies)
+	}
+}
+
+func Test_UserModelSetActiveCancelsDeletion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	users := UserModel{db}
+	if err := users.ScheduleDeletion(1, time.Now().Add(-time.Minute), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetActive(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetActive(1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := users.PurgeDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want the reactivation to have cancelled the deletion; got %d purged", n)
+	}
+	if _, err := users.Get(1); err != nil {
+		t.Errorf("want the account kept; got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("oidc: invalid id token")
+
+// Config describes the relying party. It can be loaded from a JSON file with
+// LoadConfig and then overridden by command-line flags.
+type Config struct {
+	// Name is shown on the login button, e.g. "Company SSO".
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("oidc: %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Claims are the ID token claims Snippetbox cares about.
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// audience accepts both forms of the aud claim, a string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = audience(ss)
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Client talks to a single OpenID Provider using the authorization code flow
+// with PKCE. The provider metadata is discovered on first use.
+type Client struct {
+	Config     Config
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	provider *discovery
+	keys     *keySet
+	now      func() time.Time
+}
+
+func New(cfg Config) *Client {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.Name == "" {
+		cfg.Name = "SSO"
+	}
+	return &Client{
+		Config:     cfg,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+}
+
+func (c *Client) discover(ctx context.Context) (*discovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return c.provider, nil
+	}
+
+	wellKnown := strings.TrimSuffix(c.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	d := &discovery{}
+	if err := c.getJSON(ctx, wellKnown, d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != c.Config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer %q does not match discovered issuer %q", c.Config.Issuer, d.Issuer)
+	}
+	c.provider = d
+	c.keys = &keySet{uri: d.JWKSURI, client: c}
+	return d, nil
+}
+
+// AuthCodeURL returns the URL to send the user to. state and nonce must be
+// random and remembered until the callback, as must verifier.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.Config.ClientID)
+	v.Set("redirect_uri", c.Config.RedirectURL)
+	v.Set("scope", strings.Join(c.Config.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", S256Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange redeems code at the token endpoint and returns the verified claims
+// of the ID token that comes back.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	d, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.Config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.Config.ClientID), url.QueryEscape(c.Config.ClientSecret))
+
+	rs, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(rs.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("oidc: decoding token response: %w", err)
+	}
+	if rs.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("oidc: token endpoint returned %d %s: %s", rs.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+
+	return c.Verify(ctx, token.IDToken, nonce)
+}
+
+// leeway allows for clock skew between us and the provider.
+const leeway = time.Minute
+
+// Verify checks the signature and the standard claims of rawIDToken.
+func (c *Client) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	if _, err := c.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	payload, err := verifySignature(ctx, c.keys, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := c.now()
+	switch {
+	case claims.Issuer != c.Config.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.Audience.contains(c.Config.ClientID):
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != c.Config.ClientID:
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidToken, claims.AuthorizedBy)
+	case now.After(time.Unix(claims.Expiry, 0).Add(leeway)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	rs, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned %d", url, rs.StatusCode)
+	}
+	return json.NewDecoder(rs.Body).Decode(v)
+}
+
+// RandomString returns a URL-safe random string suitable for state, nonce
+// and PKCE code verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256Challenge derives the PKCE code challenge for verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,139 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/oidc/oidctest"
+)
+
+func Test_ClientAuthorizationCodeFlow(t *testing.T) {
+	p := oidctest.NewProvider("snippetbox", "s3cret")
+	defer p.Close()
+
+	c := New(Config{
+		Issuer:       p.Issuer(),
+		ClientID:     "snippetbox",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://snippetbox.test/callback",
+	})
+	ctx := context.Background()
+
+	verifier, err := RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := c.AuthCodeURL(ctx, "state123", "nonce123", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	rs, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	loc, err := url.Parse(rs.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loc.Query().Get("state"); got != "state123" {
+		t.Errorf("want state %q; got %q", "state123", got)
+	}
+	code := loc.Query().Get("code")
+
+	t.Run("Wrong verifier", func(t *testing.T) {
+		_, err := c.Exchange(ctx, code, "wrong", "nonce123")
+		if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+			t.Errorf("want invalid_grant; got %v", err)
+		}
+	})
+
+	// The failed exchange above burned the code, so start again.
+	rs, err = client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	loc, _ = url.Parse(rs.Header.Get("Location"))
+	code = loc.Query().Get("code")
+
+	claims, err := c.Exchange(ctx, code, verifier, "nonce123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != p.User.Subject || claims.Email != p.User.Email || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func Test_ClientVerify(t *testing.T) {
+	p := oidctest.NewProvider("snippetbox", "s3cret")
+	defer p.Close()
+
+	c := New(Config{Issuer: p.Issuer(), ClientID: "snippetbox"})
+	now := time.Now()
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   p.Issuer(),
+			"sub":   "alice",
+			"aud":   "snippetbox",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "n",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(map[string]interface{})
+		token   func(string) string
+		wantErr bool
+	}{
+		{"Valid", func(map[string]interface{}) {}, nil, false},
+		{"Audience array", func(c map[string]interface{}) {
+			c["aud"] = []string{"snippetbox", "other"}
+			c["azp"] = "snippetbox"
+		}, nil, false},
+		{"Wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.test" }, nil, true},
+		{"Wrong audience", func(c map[string]interface{}) { c["aud"] = "other" }, nil, true},
+		{"Expired", func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() }, nil, true},
+		{"Wrong nonce", func(c map[string]interface{}) { c["nonce"] = "x" }, nil, true},
+		{"Tampered payload", func(map[string]interface{}) {}, func(tok string) string {
+			parts := strings.Split(tok, ".")
+			parts[1] = parts[1][:len(parts[1])-2] + "AA"
+			return strings.Join(parts, ".")
+		}, true},
+		{"Unsigned", func(map[string]interface{}) {}, func(tok string) string {
+			parts := strings.Split(tok, ".")
+			return "eyJhbGciOiJub25lIn0." + parts[1] + "."
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			tok := p.Sign(claims)
+			if tt.token != nil {
+				tok = tt.token(tok)
+			}
+			_, err := c.Verify(context.Background(), tok, "n")
+			if tt.wantErr && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("want %v; got %v", ErrInvalidToken, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("want no error; got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often the memory store forgets idle buckets.
+const pruneInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+// MemoryStore keeps buckets in process memory. It is only suitable for a
+// single instance; use the MySQL store when running several.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (m *MemoryStore) Update(key string, ttl time.Duration, fn func(float64, time.Time) (float64, time.Time)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{}
+		m.buckets[key] = b
+	}
+	b.tokens, b.updated = fn(b.tokens, b.updated)
+	b.expires = b.updated.Add(ttl)
+	m.prune(b.updated)
+	return nil
+}
+
+// prune drops idle buckets at most once per pruneInterval so the map does not
+// grow without bound. The caller must hold m.mu.
+func (m *MemoryStore) prune(now time.Time) {
+	if now.Sub(m.lastPrune) < pruneInterval {
+		return
+	}
+	for k, b := range m.buckets {
+		if now.After(b.expires) {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastPrune = now
+}
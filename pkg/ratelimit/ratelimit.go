@@ -0,0 +1,75 @@
+// Package ratelimit limits how often a client can make requests with token
+// buckets. Each key, such as "ip:203.0.113.7" or "user:42", has a bucket of
+// Burst tokens. Every request takes a token, and tokens are added back at a
+// steady rate, so short bursts are allowed but the sustained rate is capped.
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Store keeps the buckets. Update must be atomic for a key, so that instances
+// sharing a store can't both take the last token.
+type Store interface {
+	// Update passes the bucket for key to fn and saves the bucket it returns.
+	// A key without a bucket is passed a zero time. The bucket may be
+	// forgotten once it has been left alone for ttl, as it is full again.
+	Update(key string, ttl time.Duration, fn func(tokens float64, updated time.Time) (float64, time.Time)) error
+}
+
+// Policy is the size of a bucket and how quickly it refills.
+type Policy struct {
+	// Burst is how many requests can be made at once.
+	Burst int
+	// Refill is how often a token is added back, up to Burst.
+	Refill time.Duration
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket and Remaining the whole tokens left.
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, if none was taken.
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow takes a token from the bucket for key, refilling it under p first.
+func (l *Limiter) Allow(key string, p Policy) (Result, error) {
+	now := l.now()
+	full := time.Duration(p.Burst) * p.Refill
+	res := Result{Limit: p.Burst}
+	err := l.store.Update(key, full, func(tokens float64, updated time.Time) (float64, time.Time) {
+		if updated.IsZero() {
+			tokens = float64(p.Burst)
+		} else if elapsed := now.Sub(updated); elapsed > 0 {
+			tokens = math.Min(float64(p.Burst), tokens+float64(elapsed)/float64(p.Refill))
+		}
+		if tokens >= 1 {
+			tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration((1 - tokens) * float64(p.Refill))
+		}
+		res.Remaining = int(tokens)
+		res.Reset = time.Duration((float64(p.Burst) - tokens) * float64(p.Refill))
+		return tokens, now
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
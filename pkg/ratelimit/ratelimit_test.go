@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Limiter(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	l := New(NewMemoryStore())
+	l.now = func() time.Time { return now }
+	p := Policy{Burst: 3, Refill: time.Second}
+
+	tests := []struct {
+		name          string
+		wait          time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantReset     time.Duration
+		wantRetry     time.Duration
+	}{
+		{"First", 0, true, 2, time.Second, 0},
+		{"Second", 0, true, 1, 2 * time.Second, 0},
+		{"Third", 0, true, 0, 3 * time.Second, 0},
+		{"Empty", 0, false, 0, 3 * time.Second, time.Second},
+		{"Partly refilled", 500 * time.Millisecond, false, 0, 2500 * time.Millisecond, 500 * time.Millisecond},
+		{"Refilled", 500 * time.Millisecond, true, 0, 3 * time.Second, 0},
+		{"Full again", time.Hour, true, 2, time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.wait)
+			res, err := l.Allow("k", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Result{Allowed: tt.wantAllowed, Limit: 3, Remaining: tt.wantRemaining, Reset: tt.wantReset, RetryAfter: tt.wantRetry}
+			if res != want {
+				t.Errorf("want %+v; got %+v", want, res)
+			}
+		})
+	}
+
+	t.Run("Other key", func(t *testing.T) {
+		res, err := l.Allow("other", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2 {
+			t.Errorf("want a full bucket; got %+v", res)
+		}
+	})
+}
+
+func Test_MemoryStorePrune(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	keep := func(tokens float64, updated time.Time) (float64, time.Time) { return tokens, now }
+
+	m.Update("idle", time.Second, keep)
+	now = now.Add(2 * pruneInterval)
+	m.Update("busy", time.Hour, keep)
+
+	if _, ok := m.buckets["idle"]; ok {
+		t.Error("want the idle bucket to be forgotten")
+	}
+	if _, ok := m.buckets["busy"]; !ok {
+		t.Error("want the busy bucket to be kept")
+	}
+}
@@ -0,0 +1,296 @@
+// Package sanitize cleans untrusted HTML with an allow-list. Elements and
+// attributes that aren't listed are dropped, keeping the text of elements
+// but not the content of scripts, styles and the like. Links may only use
+// http, https and mailto URLs, and open tags are closed, so the result can be
+// embedded in a page as it is.
+package sanitize
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// elements lists the allowed elements with their allowed attributes.
+var elements = map[string]map[string]bool{
+	"a":          {"href": true, "title": true},
+	"abbr":       {"title": true},
+	"b":          {},
+	"blockquote": {},
+	"br":         {},
+	"code":       {"class": true},
+	"dd":         {},
+	"del":        {},
+	"details":    {},
+	"dl":         {},
+	"dt":         {},
+	"em":         {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src": true, "alt": true, "title": true, "width": true, "height": true},
+	"kbd":        {},
+	"li":         {},
+	"ol":         {"start": true},
+	"p":          {},
+	"pre":        {},
+	"s":          {},
+	"span":       {"class": true},
+	"strong":     {},
+	"sub":        {},
+	"summary":    {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"align": true},
+	"th":         {"align": true},
+	"thead":      {},
+	"tr":         {},
+	"ul":         {},
+}
+
+// void elements have no end tag.
+var void = map[string]bool{"br": true, "hr": true, "img": true}
+
+// hidden elements are dropped with everything in them.
+var hidden = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+	"textarea": true, "title": true, "noscript": true, "svg": true, "math": true,
+}
+
+var schemes = map[string]map[string]bool{
+	"href": {"http": true, "https": true, "mailto": true},
+	"src":  {"http": true, "https": true},
+}
+
+// classRX matches the classes of highlighted code.
+var classRX = regexp.MustCompile(`^(hl-[a-z]+|language-[\w+#.-]+)$`)
+
+type attr struct {
+	name, value string
+}
+
+type cleaner struct {
+	b    strings.Builder
+	open []string
+}
+
+// HTML returns src without anything that isn't allowed.
+func HTML(src string) template.HTML {
+	c := &cleaner{}
+	for len(src) > 0 {
+		i := strings.IndexByte(src, '<')
+		if i < 0 {
+			c.text(src)
+			break
+		}
+		c.text(src[:i])
+		src = c.tag(src[i:])
+	}
+	for len(c.open) > 0 {
+		c.end(c.open[len(c.open)-1])
+	}
+	return template.HTML(c.b.String())
+}
+
+func (c *cleaner) text(s string) {
+	c.b.WriteString(html.EscapeString(html.UnescapeString(s)))
+}
+
+// tag handles the markup at the start of s, which starts with <, and returns
+// the rest of s.
+func (c *cleaner) tag(s string) string {
+	switch {
+	case strings.HasPrefix(s, "<!--"):
+		if end := strings.Index(s[4:], "-->"); end >= 0 {
+			return s[4+end+3:]
+		}
+		return ""
+	case strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?"):
+		if end := strings.IndexByte(s, '>'); end >= 0 {
+			return s[end+1:]
+		}
+		return ""
+	}
+
+	closing := strings.HasPrefix(s, "</")
+	rest := strings.TrimPrefix(s[1:], "/")
+	n := 0
+	for n < len(rest) && (isLetter(rest[n]) || (n > 0 && rest[n] >= '0' && rest[n] <= '9')) {
+		n++
+	}
+	if n == 0 {
+		c.text("<")
+		return s[1:]
+	}
+	name := strings.ToLower(rest[:n])
+	attrs, rest := parseAttrs(rest[n:])
+
+	switch {
+	case closing:
+		c.end(name)
+	case hidden[name]:
+		return skipElement(rest, name)
+	case elements[name] != nil:
+		c.start(name, attrs)
+	}
+	return rest
+}
+
+func (c *cleaner) start(name string, attrs []attr) {
+	c.b.WriteString("<" + name)
+	seen := map[string]bool{}
+	for _, a := range attrs {
+		if !elements[name][a.name] || seen[a.name] {
+			continue
+		}
+		value, ok := cleanAttr(a.name, a.value)
+		if !ok {
+			continue
+		}
+		seen[a.name] = true
+		c.b.WriteString(" " + a.name + `="` + html.EscapeString(value) + `"`)
+	}
+	if name == "a" {
+		c.b.WriteString(` rel="nofollow noopener"`)
+	}
+	c.b.WriteString(">")
+	if !void[name] {
+		c.open = append(c.open, name)
+	}
+}
+
+// end closes name and the elements opened inside it. End tags for elements
+// that aren't open are ignored.
+func (c *cleaner) end(name string) {
+	for i := len(c.open) - 1; i >= 0; i-- {
+		if c.open[i] != name {
+			continue
+		}
+		for j := len(c.open) - 1; j >= i; j-- {
+			c.b.WriteString("</" + c.open[j] + ">")
+		}
+		c.open = c.open[:i]
+		return
+	}
+}
+
+func cleanAttr(name, value string) (string, bool) {
+	value = html.UnescapeString(value)
+	switch name {
+	case "href", "src":
+		// Browsers ignore whitespace and control characters in URLs, so
+		// they can't be used to hide a scheme.
+		value = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, value)
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "" && !schemes[name][strings.ToLower(u.Scheme)]) {
+			return "", false
+		}
+		return u.String(), true
+	case "class":
+		var classes []string
+		for _, class := range strings.Fields(value) {
+			if classRX.MatchString(class) {
+				classes = append(classes, class)
+			}
+		}
+		return strings.Join(classes, " "), len(classes) > 0
+	}
+	return value, true
+}
+
+// parseAttrs parses the attributes of a tag up to its closing >, and returns
+// them with the rest of s.
+func parseAttrs(s string) ([]attr, string) {
+	var attrs []attr
+	i := 0
+	for i < len(s) {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return attrs, s[i+1:]
+		}
+
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		if i == start {
+			// A stray = or quote.
+			i++
+			continue
+		}
+		a := attr{name: strings.ToLower(s[start:i])}
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return attrs, ""
+				}
+				a.value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				a.value = s[start:i]
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, ""
+}
+
+// skipElement returns what follows the end tag of name in s, or nothing if it
+// isn't closed.
+func skipElement(s, name string) string {
+	lower := strings.ToLower(s)
+	for i := 0; ; {
+		end := strings.Index(lower[i:], "</"+name)
+		if end < 0 {
+			return ""
+		}
+		i += end + 2 + len(name)
+		if i < len(s) && isLetter(s[i]) {
+			continue
+		}
+		if gt := strings.IndexByte(s[i:], '>'); gt >= 0 {
+			return s[i+gt+1:]
+		}
+		return ""
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,37 @@
+package sanitize
+
+import "testing"
+
+func Test_HTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Allowed", `<p>Hi <strong>there</strong></p>`, `<p>Hi <strong>there</strong></p>`},
+		{"Text", `a < b && "c"`, `a &lt; b &amp;&amp; &#34;c&#34;`},
+		{"Script", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"Unclosed script", `a<script>alert(1)`, `a`},
+		{"Unknown element", `<marquee>hi</marquee>`, `hi`},
+		{"Event handler", `<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{"Link", `<a href="https://go.dev" target="_blank">go</a>`, `<a href="https://go.dev" rel="nofollow noopener">go</a>`},
+		{"JavaScript link", `<a href="java&#x09;script:alert(1)">x</a>`, `<a rel="nofollow noopener">x</a>`},
+		{"Image", `<img src="https://example.com/a.png" alt="a" onerror="x">`, `<img src="https://example.com/a.png" alt="a">`},
+		{"Data image", `<img src="data:image/png;base64,AAAA">`, `<img>`},
+		{"Classes", `<span class="hl-kw evil">if</span>`, `<span class="hl-kw">if</span>`},
+		{"Unclosed", `<ul><li>one`, `<ul><li>one</li></ul>`},
+		{"Stray end tag", `</div>text</p>`, `text`},
+		{"Misnested", `<em><strong>a</em>b`, `<em><strong>a</strong></em>b`},
+		{"Comment", `a<!-- <script> -->b`, `ab`},
+		{"Quoted >", `<a title="a>b" href="/x">x</a>`, `<a title="a&gt;b" href="/x" rel="nofollow noopener">x</a>`},
+		{"Not a tag", `1 <2`, `1 &lt;2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(HTML(tt.src)); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
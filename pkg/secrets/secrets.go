@@ -0,0 +1,183 @@
+// Package secrets finds credentials pasted into text, such as cloud keys,
+// private keys and database DSNs. Rules are regular expressions; the loose
+// ones also require the secret to look random enough, measured by its
+// Shannon entropy, so that placeholders like "changeme" aren't reported.
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces secrets in redacted text.
+const Redacted = "[REDACTED]"
+
+// Rule describes one kind of secret.
+type Rule struct {
+	Name        string
+	Description string
+	RX          *regexp.Regexp
+	// Group is the submatch holding the secret. Zero means the whole match.
+	Group int
+	// MinEntropy, if set, is the entropy in bits per character the secret
+	// must have.
+	MinEntropy float64
+}
+
+// Rules are checked in order, and a secret is reported by the first rule that
+// finds it.
+var Rules = []*Rule{
+	{
+		Name:        "private-key",
+		Description: "Private key",
+		RX:          regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----|\z)`),
+	},
+	{
+		Name:        "aws-access-key-id",
+		Description: "AWS access key ID",
+		RX:          regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Name:        "aws-secret-access-key",
+		Description: "AWS secret access key",
+		RX:          regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?["']?\s*[:=]+\s*["']?([A-Za-z0-9/+]{40})\b`),
+		Group:       1,
+		MinEntropy:  3.5,
+	},
+	{
+		Name:        "github-token",
+		Description: "GitHub token",
+		RX:          regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	},
+	{
+		Name:        "slack-token",
+		Description: "Slack token",
+		RX:          regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	},
+	{
+		Name:        "mysql-dsn",
+		Description: "Database DSN",
+		RX:          regexp.MustCompile(`[\w.-]+:[^\s@/'"]*@(?:(?:tcp|unix)?\([^)\s]*\))?/\w+`),
+	},
+	{
+		Name:        "url-password",
+		Description: "Password in a URL",
+		RX:          regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s:/@'"]+:([^\s@/'"]+)@`),
+		Group:       1,
+	},
+	{
+		Name:        "generic-secret",
+		Description: "Password, token or API key",
+		RX:          regexp.MustCompile("(?i)(?:api[_-]?key|secret(?:[_-]?key)?|access[_-]?token|auth[_-]?token|password|passwd|token)[\"']?\\s*[:=]+\\s*[\"'`]?([^\\s\"'`,;()]{12,})"),
+		Group:       1,
+		MinEntropy:  3.5,
+	},
+}
+
+// Match is a secret found in a text.
+type Match struct {
+	Rule *Rule
+	// Line is the number of the line the secret starts on.
+	Line int
+	// Start and End are the byte offsets of the secret.
+	Start, End int
+	// Text is the line, with the secret masked.
+	Text string
+}
+
+// Scan returns the secrets in content, in order.
+func Scan(content string) []*Match {
+	var matches []*Match
+	for _, rule := range Rules {
+		for _, loc := range rule.RX.FindAllStringSubmatchIndex(content, -1) {
+			start, end := loc[2*rule.Group], loc[2*rule.Group+1]
+			if start < 0 {
+				continue
+			}
+			if rule.MinEntropy > 0 && Entropy(content[start:end]) < rule.MinEntropy {
+				continue
+			}
+			if overlaps(matches, start, end) {
+				continue
+			}
+			matches = append(matches, &Match{Rule: rule, Start: start, End: end})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	for _, m := range matches {
+		m.Line = strings.Count(content[:m.Start], "\n") + 1
+		lineStart := strings.LastIndexByte(content[:m.Start], '\n') + 1
+		lineEnd := strings.IndexByte(content[m.Start:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += m.Start
+		}
+		rest := ""
+		if m.End < lineEnd {
+			rest = content[m.End:lineEnd]
+		}
+		m.Text = strings.TrimSpace(content[lineStart:m.Start] + mask(content[m.Start:m.End]) + rest)
+	}
+	return matches
+}
+
+func overlaps(matches []*Match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && m.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns content with the secrets of matches, which were found in
+// it by Scan, replaced by Redacted.
+func Redact(content string, matches []*Match) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(content[last:m.Start])
+		b.WriteString(Redacted)
+		last = m.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// mask keeps the first few characters of a secret on its first line, so it
+// can be recognised without being shown.
+func mask(secret string) string {
+	if i := strings.IndexByte(secret, '\n'); i >= 0 {
+		secret = secret[:i]
+	}
+	keep := len(secret) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	return secret[:keep] + strings.Repeat("*", len(secret)-keep)
+}
+
+// Entropy returns the Shannon entropy of s in bits per character.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
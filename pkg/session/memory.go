@@ -0,0 +1,82 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart
+// and are not shared between instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (m *MemoryStore) Find(id string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[id]
+	if !ok || !rec.Expiry.After(time.Now()) {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (m *MemoryStore) Save(rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[rec.ID] = *rec
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, id)
+	return nil
+}
+
+func (m *MemoryStore) List(userID int) ([]*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	records := []*Record{}
+	for _, rec := range m.records {
+		if rec.UserID == userID && rec.Expiry.After(now) {
+			rec := rec
+			records = append(records, &rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastSeen.After(records[j].LastSeen)
+	})
+	return records, nil
+}
+
+func (m *MemoryStore) DeleteByUser(userID int, exceptID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, rec := range m.records {
+		if rec.UserID == userID && id != exceptID {
+			delete(m.records, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpired() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, rec := range m.records {
+		if !rec.Expiry.After(now) {
+			delete(m.records, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record is a session as it is kept in a Store. The cookie only carries an
+// opaque token; the store is keyed by the SHA-256 hash of that token, so a
+// leaked store cannot be used to hijack sessions.
+type Record struct {
+	ID        string
+	UserID    int
+	Data      []byte
+	UserAgent string
+	IP        string
+	Created   time.Time
+	LastSeen  time.Time
+	Expiry    time.Time
+}
+
+// Store persists session records.
+type Store interface {
+	// Find returns the unexpired record with the given ID, or nil if there
+	// is none.
+	Find(id string) (*Record, error)
+	// Save inserts or replaces rec.
+	Save(rec *Record) error
+	Delete(id string) error
+	// List returns the unexpired records belonging to userID, most recently
+	// seen first.
+	List(userID int) ([]*Record, error)
+	// DeleteByUser deletes every record belonging to userID except the one
+	// with ID exceptID, which may be empty.
+	DeleteByUser(userID int, exceptID string) error
+	DeleteExpired() error
+}
+
+type contextKey string
+
+const contextKeySession = contextKey("session")
+
+// touchInterval is how stale LastSeen may get before an otherwise unmodified
+// session is written back to the store.
+const touchInterval = time.Minute
+
+type Session struct {
+	Store    Store
+	Lifetime time.Duration
+	// UserIDKey is the key whose int value is recorded as the record's
+	// UserID, which is what List and DeleteByUser look at.
+	UserIDKey string
+	Cookie    http.Cookie
+	ErrorFunc func(http.ResponseWriter, *http.Request, error)
+}
+
+func New(store Store) *Session {
+	return &Session{
+		Store:    store,
+		Lifetime: 24 * time.Hour,
+		Cookie: http.Cookie{
+			Name:     "session",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		ErrorFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		},
+	}
+}
+
+type state struct {
+	mu        sync.Mutex
+	rec       *Record
+	values    map[string]interface{}
+	modified  bool
+	renew     bool
+	destroyed bool
+	committed bool
+}
+
+func (s *Session) Enable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := &state{values: map[string]interface{}{}}
+		if c, err := r.Cookie(s.Cookie.Name); err == nil {
+			rec, err := s.Store.Find(hashToken(c.Value))
+			if err != nil {
+				s.ErrorFunc(w, r, err)
+				return
+			}
+			if rec != nil {
+				if err := gob.NewDecoder(bytes.NewReader(rec.Data)).Decode(&st.values); err != nil {
+					s.ErrorFunc(w, r, err)
+					return
+				}
+				st.rec = rec
+			}
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), contextKeySession, st))
+		sw := &sessionWriter{ResponseWriter: w, session: s, request: r, state: st}
+		next.ServeHTTP(sw, r)
+		if !sw.written {
+			sw.commit()
+		}
+	})
+}
+
+type sessionWriter struct {
+	http.ResponseWriter
+	session *Session
+	request *http.Request
+	state   *state
+	written bool
+	failed  bool
+}
+
+func (sw *sessionWriter) WriteHeader(code int) {
+	if !sw.written {
+		sw.commit()
+	}
+	if sw.failed {
+		return
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	if !sw.written {
+		sw.commit()
+	}
+	if sw.failed {
+		return len(b), nil
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// commit saves the session before the first byte of the response goes out.
+// If that fails the error page replaces the handler's response.
+func (sw *sessionWriter) commit() {
+	sw.written = true
+	if err := sw.session.commit(sw.ResponseWriter, sw.request, sw.state); err != nil {
+		sw.failed = true
+		sw.session.ErrorFunc(sw.ResponseWriter, sw.request, err)
+	}
+}
+
+func (s *Session) commit(w http.ResponseWriter, r *http.Request, st *state) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.committed {
+		return nil
+	}
+	st.committed = true
+
+	now := time.Now().UTC()
+
+	if st.destroyed {
+		if st.rec != nil {
+			if err := s.Store.Delete(st.rec.ID); err != nil {
+				return err
+			}
+			st.rec = nil
+		}
+		// Anything Put after Destroy starts a brand new session.
+		if len(st.values) == 0 {
+			s.writeCookie(w, "", time.Unix(1, 0))
+			return nil
+		}
+	}
+
+	if !st.modified && !st.renew {
+		if st.rec == nil || now.Sub(st.rec.LastSeen) < touchInterval {
+			return nil
+		}
+		st.rec.LastSeen = now
+		st.rec.UserAgent = r.UserAgent()
+		st.rec.IP = remoteIP(r)
+		return s.Store.Save(st.rec)
+	}
+
+	data := new(bytes.Buffer)
+	if err := gob.NewEncoder(data).Encode(st.values); err != nil {
+		return err
+	}
+
+	token := ""
+	rec := st.rec
+	if rec == nil || st.renew {
+		if rec != nil {
+			if err := s.Store.Delete(rec.ID); err != nil {
+				return err
+			}
+		}
+		var err error
+		token, err = generateToken()
+		if err != nil {
+			return err
+		}
+		rec = &Record{
+			ID:      hashToken(token),
+			Created: now,
+			Expiry:  now.Add(s.Lifetime),
+		}
+		st.rec = rec
+	}
+
+	rec.Data = data.Bytes()
+	rec.UserID, _ = st.values[s.UserIDKey].(int)
+	rec.UserAgent = r.UserAgent()
+	rec.IP = remoteIP(r)
+	rec.LastSeen = now
+	if err := s.Store.Save(rec); err != nil {
+		return err
+	}
+	if token != "" {
+		s.writeCookie(w, token, rec.Expiry)
+	}
+	return nil
+}
+
+func (s *Session) writeCookie(w http.ResponseWriter, value string, expires time.Time) {
+	c := s.Cookie
+	c.Value = value
+	c.Expires = expires
+	if value == "" {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, &c)
+}
+
+func (s *Session) getState(r *http.Request) *state {
+	st, ok := r.Context().Value(contextKeySession).(*state)
+	if !ok {
+		panic("session: Enable middleware has not been used for this request")
+	}
+	return st
+}
+
+func (s *Session) Put(r *http.Request, key string, val interface{}) {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.values[key] = val
+	st.modified = true
+}
+
+func (s *Session) Get(r *http.Request, key string) interface{} {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.values[key]
+}
+
+func (s *Session) Pop(r *http.Request, key string) interface{} {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	val, ok := st.values[key]
+	if !ok {
+		return nil
+	}
+	delete(st.values, key)
+	st.modified = true
+	return val
+}
+
+func (s *Session) Remove(r *http.Request, key string) {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.values[key]; !ok {
+		return
+	}
+	delete(st.values, key)
+	st.modified = true
+}
+
+func (s *Session) Exists(r *http.Request, key string) bool {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, ok := st.values[key]
+	return ok
+}
+
+func (s *Session) GetString(r *http.Request, key string) string {
+	val, _ := s.Get(r, key).(string)
+	return val
+}
+
+func (s *Session) GetInt(r *http.Request, key string) int {
+	val, _ := s.Get(r, key).(int)
+	return val
+}
+
+func (s *Session) GetBool(r *http.Request, key string) bool {
+	val, _ := s.Get(r, key).(bool)
+	return val
+}
+
+func (s *Session) PopString(r *http.Request, key string) string {
+	val, _ := s.Pop(r, key).(string)
+	return val
+}
+
+// RenewToken issues a new token for the current session, keeping its data.
+// It should be called whenever the privilege level changes, such as on login,
+// to prevent session fixation.
+func (s *Session) RenewToken(r *http.Request) {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.renew = true
+}
+
+// Destroy deletes the current session from the store and expires the cookie.
+func (s *Session) Destroy(r *http.Request) {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.values = map[string]interface{}{}
+	st.destroyed = true
+}
+
+// ID returns the store ID of the current session, or "" if it has not been
+// saved yet.
+func (s *Session) ID(r *http.Request) string {
+	st := s.getState(r)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.rec == nil {
+		return ""
+	}
+	return st.rec.ID
+}
+
+// List returns the sessions belonging to userID.
+func (s *Session) List(userID int) ([]*Record, error) {
+	return s.Store.List(userID)
+}
+
+// Revoke deletes the session with the given ID if it belongs to userID. It
+// reports whether a session was deleted.
+func (s *Session) Revoke(userID int, id string) (bool, error) {
+	rec, err := s.Store.Find(id)
+	if err != nil || rec == nil || rec.UserID != userID {
+		return false, err
+	}
+	return true, s.Store.Delete(id)
+}
+
+// RevokeAll deletes every session belonging to userID.
+func (s *Session) RevokeAll(userID int) error {
+	return s.Store.DeleteByUser(userID, "")
+}
+
+// RevokeOthers deletes every session belonging to userID except the current
+// one.
+func (s *Session) RevokeOthers(r *http.Request, userID int) error {
+	return s.Store.DeleteByUser(userID, s.ID(r))
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
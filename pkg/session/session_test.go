@@ -0,0 +1,79 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Session(t *testing.T) {
+	store := NewMemoryStore()
+	s := New(store)
+	s.UserIDKey = "userID"
+
+	var handler http.HandlerFunc
+	h := s.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+	}))
+
+	cookie := &http.Cookie{}
+	do := func(f http.HandlerFunc) *http.Response {
+		handler = f
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie.Value != "" {
+			r.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		rs := rr.Result()
+		for _, c := range rs.Cookies() {
+			if c.Name == s.Cookie.Name {
+				cookie = c
+			}
+		}
+		return rs
+	}
+
+	do(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r, "userID", 7)
+	})
+	firstToken := cookie.Value
+	if firstToken == "" {
+		t.Fatal("want a session cookie")
+	}
+
+	records, err := store.List(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("want 1 session for user; got %d", len(records))
+	}
+
+	do(func(w http.ResponseWriter, r *http.Request) {
+		if got := s.GetInt(r, "userID"); got != 7 {
+			t.Errorf("want userID 7; got %d", got)
+		}
+		s.RenewToken(r)
+	})
+	if cookie.Value == firstToken {
+		t.Error("want a new token after RenewToken")
+	}
+	if rec, _ := store.Find(hashToken(firstToken)); rec != nil {
+		t.Error("want the old token to be forgotten")
+	}
+
+	do(func(w http.ResponseWriter, r *http.Request) {
+		s.Destroy(r)
+	})
+	if cookie.MaxAge >= 0 {
+		t.Error("want the cookie to be expired")
+	}
+	records, err = store.List(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("want no sessions for user; got %d", len(records))
+	}
+}
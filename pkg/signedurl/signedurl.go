@@ -0,0 +1,65 @@
+// Package signedurl makes links that can be shared without a session but
+// can't be forged or used after they expire.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("signedurl: invalid signature")
+	ErrExpired          = errors.New("signedurl: link has expired")
+)
+
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+func New(key []byte) *Signer {
+	return &Signer{key: key, now: time.Now}
+}
+
+// Sign returns path with expires and sig query parameters added.
+func (s *Signer) Sign(path string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	v := url.Values{}
+	v.Set("expires", exp)
+	v.Set("sig", s.signature(path, exp))
+	return path + "?" + v.Encode()
+}
+
+// Verify checks a URL made by Sign. Only the path and the expires and sig
+// parameters are covered by the signature.
+func (s *Signer) Verify(u *url.URL) error {
+	q := u.Query()
+	exp := q.Get("expires")
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(s.signature(u.Path, exp))
+	if !hmac.Equal(sig, want) {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !s.now().Before(time.Unix(unix, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) signature(path, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,43 @@
+package signedurl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SignerVerify(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New([]byte("secret"))
+	s.now = func() time.Time { return now }
+
+	link := s.Sign("/export/abc.zip", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		link    string
+		signer  *Signer
+		wantErr error
+	}{
+		{"Valid", link, s, nil},
+		{"Other path", strings.Replace(link, "abc", "xyz", 1), s, ErrInvalidSignature},
+		{"Extended expiry", strings.Replace(link, "expires=", "expires=9", 1), s, ErrInvalidSignature},
+		{"Missing signature", "/export/abc.zip", s, ErrInvalidSignature},
+		{"Other key", link, &Signer{key: []byte("other"), now: s.now}, ErrInvalidSignature},
+		{"Expired", link, &Signer{key: s.key, now: func() time.Time { return now.Add(2 * time.Hour) }}, ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.signer.Verify(u); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
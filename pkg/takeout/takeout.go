@@ -0,0 +1,164 @@
+// Package takeout builds the archive of everything held about a user.
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+// Archive is the data that goes into a takeout.
+type Archive struct {
+	User     *models.User
+	Snippets []*models.Snippet
+	// Revisions are the earlier versions of each snippet, by snippet ID.
+	Revisions map[int][]*models.Revision
+	Events    []*audit.Event
+}
+
+type profile struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Username      string    `json:"username,omitempty"`
+	Email         string    `json:"email"`
+	Created       time.Time `json:"created"`
+	Role          string    `json:"role"`
+	ProfilePublic bool      `json:"profile_public"`
+}
+
+type manifestEntry struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Dir     string    `json:"dir"`
+	Files   []string  `json:"files"`
+	Tags    []string  `json:"tags,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Hidden  bool      `json:"hidden"`
+	// ForkedFrom is the ID of the snippet this one was forked from, if any.
+	ForkedFrom int             `json:"forked_from,omitempty"`
+	Revisions  []revisionEntry `json:"revisions,omitempty"`
+}
+
+type revisionEntry struct {
+	Title    string    `json:"title"`
+	Dir      string    `json:"dir"`
+	Files    []string  `json:"files"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// Write writes a as a ZIP archive containing profile.json,
+// snippets/manifest.json with a directory of files per snippet, and
+// audit-events.jsonl. Earlier versions of a snippet are in numbered
+// directories under its revisions directory, oldest first.
+func Write(w io.Writer, a *Archive) error {
+	zw := zip.NewWriter(w)
+
+	err := writeJSON(zw, "profile.json", profile{
+		ID:            a.User.ID,
+		Name:          a.User.Name,
+		Username:      a.User.Username,
+		Email:         a.User.Email,
+		Created:       a.User.Created,
+		Role:          a.User.Role,
+		ProfilePublic: a.User.ProfilePublic,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest := []manifestEntry{}
+	for _, s := range a.Snippets {
+		dir := fmt.Sprintf("snippets/%d-%s", s.ID, slug(s.Title))
+		names, err := writeFiles(zw, dir, s.Files)
+		if err != nil {
+			return err
+		}
+		var revisions []revisionEntry
+		for i, rev := range a.Revisions[s.ID] {
+			revDir := fmt.Sprintf("%s/revisions/%d", dir, i+1)
+			revNames, err := writeFiles(zw, revDir, rev.Files)
+			if err != nil {
+				return err
+			}
+			revisions = append(revisions, revisionEntry{
+				Title:    rev.Title,
+				Dir:      revDir,
+				Files:    revNames,
+				Replaced: rev.Replaced,
+			})
+		}
+		manifest = append(manifest, manifestEntry{
+			ID:         s.ID,
+			Title:      s.Title,
+			Dir:        dir,
+			Files:      names,
+			Tags:       s.Tags,
+			Created:    s.Created,
+			Expires:    s.Expires,
+			Hidden:     s.Hidden,
+			ForkedFrom: s.ForkedFromID,
+			Revisions:  revisions,
+		})
+	}
+	if err := writeJSON(zw, "snippets/manifest.json", manifest); err != nil {
+		return err
+	}
+
+	f, err := zw.Create("audit-events.jsonl")
+	if err != nil {
+		return err
+	}
+	if err := audit.WriteJSONL(f, a.Events); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeFiles writes files to dir and returns their names.
+func writeFiles(zw *zip.Writer, dir string, files []*models.File) ([]string, error) {
+	names := []string{}
+	for _, file := range files {
+		f, err := zw.Create(dir + "/" + file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, file.Content); err != nil {
+			return nil, err
+		}
+		names = append(names, file.Name)
+	}
+	return names, nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+var nonSlugRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug turns a title into something safe to use in a file name.
+func slug(title string) string {
+	s := strings.Trim(nonSlugRX.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(s) > 50 {
+		s = strings.TrimRight(s[:50], "-")
+	}
+	if s == "" {
+		return "untitled"
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package takeout
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aesuhaendi/go-snippetbox/pkg/audit"
+	"github.com/aesuhaendi/go-snippetbox/pkg/models"
+)
+
+func Test_Write(t *testing.T) {
+	a := &Archive{
+		User: &models.User{ID: 1, Name: "Alice", Email: "alice@example.com", HashedPassword: []byte("secret")},
+		Snippets: []*models.Snippet{
+			{ID: 7, Title: "An old silent pond", Files: []*models.File{{Name: "haiku.txt", Content: "An old silent pond..."}}, Expires: time.Now()},
+			{ID: 8, Title: "???", Files: []*models.File{{Name: "a.txt", Content: "?"}, {Name: "b.go", Content: "package b"}}},
+		},
+		Revisions: map[int][]*models.Revision{
+			7: {{ID: 1, Title: "An old pond", Files: []*models.File{{Name: "haiku.txt", Content: "An old pond..."}}}},
+		},
+		Events: []*audit.Event{{ID: 1, Action: audit.ActionLogin, ActorID: 1}},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Write(buf, a); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want []byte
+	}{
+		{"Profile", "profile.json", []byte(`"email": "alice@example.com"`)},
+		{"Snippet", "snippets/7-an-old-silent-pond/haiku.txt", []byte("An old silent pond...")},
+		{"Untitled snippet", "snippets/8-untitled/a.txt", []byte("?")},
+		{"Second file", "snippets/8-untitled/b.go", []byte("package b")},
+		{"Revision", "snippets/7-an-old-silent-pond/revisions/1/haiku.txt", []byte("An old pond...")},
+		{"Manifest", "snippets/manifest.json", []byte(`"dir": "snippets/7-an-old-silent-pond"`)},
+		{"Manifest revision", "snippets/manifest.json", []byte(`"dir": "snippets/7-an-old-silent-pond/revisions/1"`)},
+		{"Audit events", "audit-events.jsonl", []byte(`"action":"user.login"`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := files[tt.file]
+			if !ok {
+				t.Fatalf("want %s in the archive", tt.file)
+			}
+			if !bytes.Contains(got, tt.want) {
+				t.Errorf("want %s to contain %q; got %q", tt.file, tt.want, got)
+			}
+		})
+	}
+
+	if bytes.Contains(files["profile.json"], []byte("secret")) {
+		t.Error("want the password hash to be left out")
+	}
+	var manifest []manifestEntry
+	if err := json.Unmarshal(files["snippets/manifest.json"], &manifest); err != nil || len(manifest) != 2 {
+		t.Errorf("want 2 manifest entries; got %v (%v)", manifest, err)
+	}
+}
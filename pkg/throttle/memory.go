@@ -0,0 +1,68 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+type entry struct {
+	failures int
+	last     time.Time
+}
+
+// MemoryStore keeps counters in process memory. It is only suitable for a
+// single instance; use the MySQL store when running several.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*entry{}}
+}
+
+func (m *MemoryStore) Get(key string) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return 0, time.Time{}, nil
+	}
+	return e.failures, e.last, nil
+}
+
+func (m *MemoryStore) Increment(key string, now time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now, window)
+	e, ok := m.entries[key]
+	if !ok || now.Sub(e.last) > window {
+		e = &entry{}
+		m.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	return e.failures, nil
+}
+
+func (m *MemoryStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// prune drops stale entries at most once per window so the map does not grow
+// without bound. The caller must hold m.mu.
+func (m *MemoryStore) prune(now time.Time, window time.Duration) {
+	if now.Sub(m.lastPrune) < window {
+		return
+	}
+	for k, e := range m.entries {
+		if now.Sub(e.last) > window {
+			delete(m.entries, k)
+		}
+	}
+	m.lastPrune = now
+}
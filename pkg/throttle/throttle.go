@@ -0,0 +1,119 @@
+package throttle
+
+import (
+	"time"
+)
+
+// Store keeps failed-attempt counters keyed by an arbitrary string, such as
+// "ip:203.0.113.7" or "account:alice@example.com".
+type Store interface {
+	// Get returns the number of failures recorded for key and the time of the
+	// most recent one. An unknown key returns zero values and no error.
+	Get(key string) (int, time.Time, error)
+	// Increment records a failure at now and returns the new count. If the
+	// previous failure is older than window the counter starts again at 1.
+	Increment(key string, now time.Time, window time.Duration) (int, error)
+	// Reset forgets every failure recorded for key.
+	Reset(key string) error
+}
+
+// Policy describes how quickly a key is slowed down and when it is locked.
+type Policy struct {
+	// BaseDelay is the wait imposed after the first failure. It doubles with
+	// every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxFailures is the number of failures after which the key is locked
+	// for LockoutDuration.
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// Window is how long a failure is remembered. A key with no failures in
+	// the last Window starts from scratch.
+	Window time.Duration
+}
+
+// Status is the outcome of checking a key against its policy.
+type Status struct {
+	Failures int
+	Wait     time.Duration
+	Locked   bool
+}
+
+// Allowed reports whether another attempt may be made right now.
+func (s Status) Allowed() bool {
+	return s.Wait <= 0
+}
+
+type Throttler struct {
+	// Now returns the current time. Tests can replace it to move time along
+	// without sleeping.
+	Now func() time.Time
+
+	store  Store
+	policy Policy
+}
+
+func New(store Store, policy Policy) *Throttler {
+	return &Throttler{
+		Now:    time.Now,
+		store:  store,
+		policy: policy,
+	}
+}
+
+// Check returns the current status of key without recording anything.
+func (t *Throttler) Check(key string) (Status, error) {
+	failures, last, err := t.store.Get(key)
+	if err != nil {
+		return Status{}, err
+	}
+	now := t.Now()
+	if failures == 0 || now.Sub(last) > t.policy.Window {
+		return Status{}, nil
+	}
+	return t.status(failures, last, now), nil
+}
+
+// Fail records a failed attempt for key and returns its new status.
+func (t *Throttler) Fail(key string) (Status, error) {
+	now := t.Now()
+	failures, err := t.store.Increment(key, now, t.policy.Window)
+	if err != nil {
+		return Status{}, err
+	}
+	return t.status(failures, now, now), nil
+}
+
+// Succeed clears the failures recorded for key.
+func (t *Throttler) Succeed(key string) error {
+	return t.store.Reset(key)
+}
+
+func (t *Throttler) status(failures int, last, now time.Time) Status {
+	s := Status{Failures: failures}
+	var until time.Time
+	if t.policy.MaxFailures > 0 && failures >= t.policy.MaxFailures {
+		s.Locked = true
+		until = last.Add(t.policy.LockoutDuration)
+	} else {
+		until = last.Add(t.delay(failures))
+	}
+	if wait := until.Sub(now); wait > 0 {
+		s.Wait = wait
+	}
+	return s
+}
+
+func (t *Throttler) delay(failures int) time.Duration {
+	if failures < 1 || t.policy.BaseDelay <= 0 {
+		return 0
+	}
+	d := t.policy.BaseDelay
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if t.policy.MaxDelay > 0 && d >= t.policy.MaxDelay {
+			return t.policy.MaxDelay
+		}
+	}
+	return d
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Throttler(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	th := New(NewMemoryStore(), Policy{
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		MaxFailures:     5,
+		LockoutDuration: 10 * time.Minute,
+		Window:          15 * time.Minute,
+	})
+	th.Now = func() time.Time { return now }
+
+	tests := []struct {
+		name       string
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"First failure", time.Second, false},
+		{"Second failure", 2 * time.Second, false},
+		{"Third failure", 4 * time.Second, false},
+		{"Fourth failure capped", 4 * time.Second, false},
+		{"Fifth failure locks", 10 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := th.Fail("k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Wait != tt.wantWait || s.Locked != tt.wantLocked {
+				t.Errorf("want wait %v locked %v; got wait %v locked %v", tt.wantWait, tt.wantLocked, s.Wait, s.Locked)
+			}
+			now = now.Add(s.Wait)
+			s, err = th.Check("k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.Allowed() {
+				t.Errorf("want allowed after waiting; got wait %v", s.Wait)
+			}
+		})
+	}
+
+	t.Run("Forgotten after window", func(t *testing.T) {
+		now = now.Add(16 * time.Minute)
+		s, err := th.Fail("k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Failures != 1 {
+			t.Errorf("want 1 failure; got %d", s.Failures)
+		}
+	})
+
+	t.Run("Succeed resets", func(t *testing.T) {
+		if err := th.Succeed("k"); err != nil {
+			t.Fatal(err)
+		}
+		s, err := th.Check("k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Failures != 0 || !s.Allowed() {
+			t.Errorf("want reset status; got %+v", s)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+CREATE TABLE antispam_tokens (
+  token_hash BINARY(32) NOT NULL PRIMARY KEY,
+  expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_antispam_tokens_expires ON antispam_tokens(expires);
@@ -0,0 +1,20 @@
+CREATE TABLE audit_events (
+  id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  created DATETIME(6) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  actor_id INTEGER,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  details JSON
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_action ON audit_events(action, created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, created);
+
+-- The audit log is append-only.
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
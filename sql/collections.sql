@@ -0,0 +1,21 @@
+CREATE TABLE collections (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  slug VARCHAR(50) NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  description TEXT NOT NULL,
+  visibility VARCHAR(20) NOT NULL DEFAULT 'private',
+  created DATETIME NOT NULL,
+  CONSTRAINT collections_uc_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_collections_user_id ON collections(user_id);
+
+CREATE TABLE collection_snippets (
+  collection_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet_id ON collection_snippets(snippet_id);
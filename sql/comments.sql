@@ -0,0 +1,22 @@
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  file VARCHAR(255) NOT NULL DEFAULT '',
+  line_start INTEGER,
+  line_end INTEGER,
+  body TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  edited DATETIME,
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  deleted BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id, created);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+
+-- Comments used to be deleted along with their replies. To keep the replies
+-- of threads whose first comment was deleted by its author:
+--
+-- ALTER TABLE comments ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+CREATE TABLE exports (
+  name CHAR(36) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  ready BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_exports_user_id ON exports(user_id);
+
+-- Archives used to be kept only as files. Those made before this table
+-- existed have no record, so they can no longer be downloaded and are removed
+-- by the next sweep of the export directory.
@@ -0,0 +1,7 @@
+CREATE TABLE login_attempts (
+  attempt_key VARCHAR(255) NOT NULL PRIMARY KEY,
+  failures INTEGER NOT NULL,
+  last_failure DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_last_failure ON login_attempts(last_failure);
@@ -0,0 +1,10 @@
+CREATE TABLE notifications (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  message VARCHAR(255) NOT NULL,
+  link VARCHAR(1024) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL,
+  is_read BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created);
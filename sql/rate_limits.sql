@@ -0,0 +1,8 @@
+CREATE TABLE rate_limits (
+  bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+  tokens DOUBLE NOT NULL,
+  updated DATETIME(6),
+  expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_expires ON rate_limits(expires);
@@ -0,0 +1,20 @@
+CREATE TABLE remember_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  selector CHAR(16) NOT NULL,
+  hashed_validator BINARY(32) NOT NULL,
+  user_id INTEGER NOT NULL,
+  family CHAR(16) NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE,
+  used_at DATETIME,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL
+);
+
+ALTER TABLE remember_tokens ADD CONSTRAINT remember_tokens_uc_selector UNIQUE (selector);
+CREATE INDEX idx_remember_tokens_family ON remember_tokens(family);
+CREATE INDEX idx_remember_tokens_user_id ON remember_tokens(user_id);
+
+-- Tokens used to be refused as soon as they had been rotated. To let a used
+-- token through for a few seconds, for requests made at the same time:
+--
+-- ALTER TABLE remember_tokens ADD COLUMN used_at DATETIME AFTER used;
@@ -0,0 +1,15 @@
+CREATE TABLE reports (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  reporter_id INTEGER NOT NULL,
+  reason VARCHAR(20) NOT NULL,
+  details VARCHAR(500) NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL DEFAULT 'open',
+  created DATETIME NOT NULL,
+  resolved_by INTEGER,
+  resolved DATETIME
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created);
+CREATE INDEX idx_reports_snippet_id ON reports(snippet_id);
+CREATE INDEX idx_reports_reporter_id ON reports(reporter_id, created);
@@ -0,0 +1,13 @@
+CREATE TABLE sessions (
+  id CHAR(64) NOT NULL PRIMARY KEY,
+  user_id INTEGER,
+  data BLOB NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expiry ON sessions(expiry);
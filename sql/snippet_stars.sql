@@ -0,0 +1,8 @@
+CREATE TABLE snippet_stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_snippet_stars_snippet_id ON snippet_stars(snippet_id, created);
@@ -1,31 +1,94 @@
-
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   title VARCHAR(100) NOT NULL,
-  content TEXT NOT NULL,
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  expires DATETIME NOT NULL,
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  user_id INTEGER,
+  forked_from_id INTEGER,
+  key_id VARCHAR(16) NOT NULL DEFAULT '',
+  data_key VARBINARY(255) NOT NULL DEFAULT ''
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_forked_from_id ON snippets(forked_from_id);
+
+CREATE TABLE snippet_files (
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content MEDIUMTEXT NOT NULL,
+  PRIMARY KEY (snippet_id, position),
+  CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name)
+);
+
+CREATE TABLE snippet_revisions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  replaced DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions(snippet_id);
+
+CREATE TABLE snippet_revision_files (
+  revision_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content MEDIUMTEXT NOT NULL,
+  PRIMARY KEY (revision_id, position)
+);
+
+-- Snippets used to have a single content column. To move it to a file:
+--
+-- INSERT INTO snippet_files (snippet_id, position, name, content)
+-- SELECT id, 0, 'snippet.txt', content FROM snippets;
+-- ALTER TABLE snippets DROP COLUMN content;
+--
+-- The content of files is encrypted when master keys are given. Older
+-- databases need the key columns, after which `snippetctl rotate-keys`
+-- encrypts the existing files:
+--
+-- ALTER TABLE snippets
+--   ADD COLUMN key_id VARCHAR(16) NOT NULL DEFAULT '',
+--   ADD COLUMN data_key VARBINARY(255) NOT NULL DEFAULT '';
 
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippets (title, created, expires) VALUES (
   'An old silent pond',
-  'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō',
   UTC_TIMESTAMP(),
   DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
 );
 
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippet_files (snippet_id, position, name, content) VALUES (
+  LAST_INSERT_ID(),
+  0,
+  'haiku.txt',
+  'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō'
+);
+
+INSERT INTO snippets (title, created, expires) VALUES (
   'Over the wintry forest',
-  'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n– Natsume Soseki',
   UTC_TIMESTAMP(),
   DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
 );
 
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippet_files (snippet_id, position, name, content) VALUES (
+  LAST_INSERT_ID(),
+  0,
+  'haiku.txt',
+  'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n– Natsume Soseki'
+);
+
+INSERT INTO snippets (title, created, expires) VALUES (
   'First autumn morning',
-  'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n– Murakami Kijo',
   UTC_TIMESTAMP(),
   DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)
-);
\ No newline at end of file
+);
+
+INSERT INTO snippet_files (snippet_id, position, name, content) VALUES (
+  LAST_INSERT_ID(),
+  0,
+  'haiku.txt',
+  'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n– Murakami Kijo'
+);
@@ -0,0 +1,10 @@
+CREATE TABLE user_identities (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  provider VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_provider_subject UNIQUE (provider, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
@@ -2,10 +2,18 @@
 CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   name VARCHAR(255) NOT NULL,
+  username VARCHAR(30),
   email VARCHAR(255) NOT NULL,
   hashed_password CHAR(60) NOT NULL,
   created DATETIME NOT NULL,
-  active BOOLEAN NOT NULL DEFAULT TRUE
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+  self_deactivated BOOLEAN NOT NULL DEFAULT FALSE,
+  delete_after DATETIME,
+  delete_snippets BOOLEAN NOT NULL DEFAULT FALSE,
+  profile_public BOOLEAN NOT NULL DEFAULT TRUE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+ALTER TABLE users ADD CONSTRAINT users_uc_username UNIQUE (username);
@@ -5,4 +5,125 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+var files = document.getElementById("files");
+if (files) {
+	var nextFile = 0;
+	var names = files.querySelectorAll("input[name^='file_name.']");
+	for (var i = 0; i < names.length; i++) {
+		var n = parseInt(names[i].name.split(".")[1], 10);
+		if (n >= nextFile) {
+			nextFile = n + 1;
+		}
+	}
+
+	document.getElementById("add-file").addEventListener("click", function () {
+		var html = document.getElementById("file-template").innerHTML;
+		files.insertAdjacentHTML("beforeend", html.replace(/__i__/g, nextFile++));
+	});
+
+	files.addEventListener("click", function (e) {
+		if (e.target.classList.contains("remove-file")) {
+			e.target.closest(".file").remove();
+		}
+	});
+}
+
+// The preview is rendered by the server, like the snippet page. It is
+// refreshed once typing pauses, and responses that arrive after a newer
+// request has been sent are ignored.
+var preview = document.getElementById("preview");
+if (preview) {
+	var previewForm = preview.closest("form");
+	var previewTimer = null;
+	var previewSeq = 0;
+
+	var updatePreview = function () {
+		var seq = ++previewSeq;
+		fetch(preview.dataset.url, {
+			method: "POST",
+			credentials: "same-origin",
+			body: new URLSearchParams(new FormData(previewForm))
+		}).then(function (response) {
+			if (!response.ok) {
+				throw new Error(response.statusText);
+			}
+			return response.text();
+		}).then(function (html) {
+			if (seq == previewSeq) {
+				preview.innerHTML = html;
+				preview.hidden = html.trim() == "";
+			}
+		}).catch(function () {});
+	};
+
+	var schedulePreview = function () {
+		clearTimeout(previewTimer);
+		previewTimer = setTimeout(updatePreview, 500);
+	};
+
+	previewForm.addEventListener("input", schedulePreview);
+	previewForm.addEventListener("click", function (e) {
+		if (e.target.id == "add-file" || e.target.classList.contains("remove-file")) {
+			schedulePreview();
+		}
+	});
+	updatePreview();
+}
+
+// The proof of work is solved as soon as the page loads, so that it is
+// usually done before the form is. Hashes are computed in batches to keep
+// the page responsive, and a form submitted early is sent once it's solved.
+var powInputs = document.querySelectorAll("input[data-challenge]");
+for (var i = 0; i < powInputs.length; i++) {
+	(function (input) {
+		var form = input.closest("form");
+		var challenge = input.dataset.challenge;
+		var difficulty = parseInt(input.dataset.difficulty, 10);
+		var encoder = new TextEncoder();
+		var solved = false;
+		var submitted = false;
+
+		var leadingZeros = function (hash) {
+			var view = new DataView(hash);
+			var zeros = 0;
+			for (var j = 0; j < view.byteLength; j += 4) {
+				var z = Math.clz32(view.getUint32(j));
+				zeros += z;
+				if (z < 32) {
+					break;
+				}
+			}
+			return zeros;
+		};
+
+		var solve = function (start) {
+			var batch = [];
+			for (var n = start; n < start + 256; n++) {
+				batch.push(crypto.subtle.digest("SHA-256", encoder.encode(challenge + n)));
+			}
+			Promise.all(batch).then(function (hashes) {
+				for (var j = 0; j < hashes.length; j++) {
+					if (leadingZeros(hashes[j]) >= difficulty) {
+						input.value = String(start + j);
+						solved = true;
+						if (submitted) {
+							form.submit();
+						}
+						return;
+					}
+				}
+				setTimeout(function () { solve(start + 256); }, 0);
+			});
+		};
+
+		form.addEventListener("submit", function (e) {
+			if (!solved) {
+				e.preventDefault();
+				submitted = true;
+			}
+		});
+		solve(0);
+	})(powInputs[i]);
+}